@@ -0,0 +1,288 @@
+package tsreflect
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// An Emitter renders types and declarations in an output format. Emit walks
+// the types of a generator and delegates the rendering of each type to it.
+type Emitter interface {
+	// Primitive returns the type of booleans, numbers, strings and
+	// interfaces.
+	Primitive(kind reflect.Kind) string
+	// Array returns the type of a slice of `elem`.
+	Array(elem string) string
+	// Tuple returns the type of a fixed size array of `elems`.
+	Tuple(elems []string) string
+	// Map returns the type of a map from `key` to `elem`.
+	Map(key, elem string) string
+	// Nullable returns `typ` or null.
+	Nullable(typ string) string
+	// Object returns the type of a struct with the properties `fields`.
+	Object(fields []Field) string
+	// Reference returns a reference to the declaration `name`.
+	Reference(name string) string
+	// Custom returns the type of a type that is only known by its TypeScript
+	// type `ts`, such as types with typers, funcs and generics.
+	Custom(ts string) string
+	// Declare returns the declaration `d`, Type holds the rendered type.
+	Declare(d Declaration) string
+}
+
+// A Field is a property of an object type. Undefined is set for optional
+// properties that can be undefined as well, with WithDefensiveOptionals.
+type Field struct {
+	Name      string
+	Type      string
+	Optional  bool
+	Readonly  bool
+	Undefined bool
+}
+
+// Emit renders the declarations of the generator with the emitter `e`, one
+// declaration per line. The declarations of TypeScriptEmitter are written like
+// DeclarationsTypeScript, with the header, exports, source references and
+// `declare global {}` wrapper that the options of the generator add.
+func (g *Generator) Emit(e Emitter) string {
+	g.rlock()
+	defer g.runlock()
+
+	e = g.emitter(e)
+	decls := g.declarationList()
+
+	declare := func(d Declaration) string {
+		typ := g.names[d.Name]
+
+		if _, ok := g.generics[typ]; !ok && typ != nil && typ.Kind() == reflect.Struct && g.enums[typ] == "" && !g.isEmptyRecord(typ) && !g.isHooked(d) {
+			d.Type = e.Object(g.emitFields(e, scope{path: d.Name, quiet: true}, typ))
+		} else {
+			d.Type = e.Custom(d.Type)
+		}
+
+		return e.Declare(d)
+	}
+
+	if _, ok := e.(TypeScriptEmitter); ok {
+		return g.frame(decls, false, declare)
+	}
+
+	lines := make([]string, len(decls))
+	for i, d := range decls {
+		lines[i] = declare(d)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// EmitType renders `typ` with the emitter `e`.
+func (g *Generator) EmitType(e Emitter, typ reflect.Type) string {
+	g.rlock()
+	defer g.runlock()
+
+	return g.emit(g.emitter(e), g.at, typ, false)
+}
+
+// emitter returns the emitter `e` configured with the options of the
+// generator, which for TypeScriptEmitter is the field terminator.
+func (g *Generator) emitter(e Emitter) Emitter {
+	switch ts := e.(type) {
+	case TypeScriptEmitter:
+		ts.terminator = g.fieldTerminator
+		return ts
+	case *TypeScriptEmitter:
+		return g.emitter(*ts)
+	default:
+		return e
+	}
+}
+
+func (g *Generator) emit(e Emitter, s scope, typ reflect.Type, optional bool) string {
+	if typ == nil {
+		return e.Primitive(reflect.Interface)
+	}
+
+	if g.isCustomEmit(s, typ) || g.isRewritten(s, typ, optional) {
+		return e.Custom(g.typeOf(s, typ, optional))
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64, reflect.Interface:
+		return e.Primitive(typ.Kind())
+	case reflect.String:
+		if name, ok := g.symbols[typ]; ok {
+			return e.Reference(name)
+		}
+
+		return e.Primitive(reflect.String)
+	case reflect.Array:
+		elem := g.emit(e, s.elem(), typ.Elem(), false)
+
+		elems := make([]string, typ.Len())
+		for i := range elems {
+			elems[i] = elem
+		}
+
+		return e.Tuple(elems)
+	case reflect.Slice:
+		elem := e.Array(g.emit(e, s.elem(), typ.Elem(), false))
+
+		if optional || g.nonNullCollections || g.compactNull {
+			return elem
+		}
+
+		return e.Nullable(elem)
+	case reflect.Map:
+		m := e.Map(g.emit(e, s, typ.Key(), false), g.emit(e, s.value(), typ.Elem(), false))
+
+		if optional || g.nonNullCollections || g.compactNull {
+			return m
+		}
+
+		return e.Nullable(m)
+	case reflect.Pointer:
+		elem := g.emit(e, s, typ.Elem(), false)
+
+		isAny := typ.Elem().Kind() == reflect.Interface && (!g.isCustomEmit(s, typ.Elem()) || g.isJSONValue(typ.Elem()))
+
+		if optional || isAny || g.compactNull {
+			return elem
+		}
+
+		return e.Nullable(elem)
+	case reflect.Struct:
+		name := g.symbols[typ]
+
+		if name == "" || g.isInline(typ) {
+			return e.Object(g.emitFields(e, s, typ))
+		}
+
+		return e.Reference(name)
+	default:
+		return e.Custom(g.typeOf(s, typ, optional))
+	}
+}
+
+// isCustomEmit reports whether `typ` is only known by its TypeScript type.
+func (g *Generator) isCustomEmit(s scope, typ reflect.Type) bool {
+	_, isParam := s.params[typ]
+	_, isNamed := g.named[typ]
+	_, isEnum := g.enums[typ]
+	_, isGeneric := g.generics[typ]
+
+	isEmpty := typ.Kind() == reflect.Struct && g.isEmptyRecord(typ)
+	isReadonly := typ.Kind() == reflect.Array && g.readonlyTuples || typ.Kind() == reflect.Slice && g.readonlySlices
+	isDeep := g.maxDepth > 0 && s.depth > g.maxDepth
+	isBranded := g.uintBrand != "" && isUnsigned(typ)
+	isValue := g.isJSONValue(typ)
+	_, isUnion := g.unions[typ]
+
+	return isParam || isNamed || isEnum || isGeneric || isEmpty || isReadonly || isDeep || isBranded || isValue || isUnion || g.hasCustomType(typ)
+}
+
+// isRewritten reports whether the type rewriter changes the TypeScript type of
+// `typ`, which is then only known by its TypeScript type.
+func (g *Generator) isRewritten(s scope, typ reflect.Type, optional bool) bool {
+	if g.typeRewriter == nil || s.rewriting {
+		return false
+	}
+
+	return g.typeOf(s, typ, optional) != g.render(s, typ, optional)
+}
+
+func (g *Generator) emitFields(e Emitter, s scope, typ reflect.Type) []Field {
+	fields := g.structFields(typ)
+
+	out := make([]Field, len(fields))
+	for i, f := range fields {
+		p := g.property(f)
+
+		out[i] = Field{
+			Name:      p.name,
+			Optional:  p.optional || f.promoted || f.omitted,
+			Readonly:  f.omitted || g.isReadonly(f),
+			Undefined: p.undefined,
+		}
+
+		switch {
+		case p.override != "":
+			out[i].Type = e.Custom(p.override)
+		case p.literal != "":
+			out[i].Type = e.Custom(p.literal)
+		case p.quoted && p.nullable:
+			out[i].Type = e.Nullable(e.Primitive(reflect.String))
+		case p.quoted:
+			out[i].Type = e.Primitive(reflect.String)
+		default:
+			out[i].Type = g.emit(e, s.field(p.name), f.Type, p.omitNull)
+		}
+	}
+
+	if g.dottedNesting {
+		out, _ = nestFields(out, e.Object)
+	}
+
+	return out
+}
+
+// TypeScriptEmitter is the Emitter of the TypeScript declarations returned by
+// DeclarationsTypeScript. Emit configures it with the field terminator of the
+// generator and frames its declarations like DeclarationsTypeScript.
+type TypeScriptEmitter struct {
+	terminator string
+}
+
+func (TypeScriptEmitter) Primitive(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Interface:
+		return "any"
+	default:
+		return "number"
+	}
+}
+
+func (TypeScriptEmitter) Array(elem string) string {
+	return fmt.Sprintf("%s[]", group(elem))
+}
+
+func (TypeScriptEmitter) Tuple(elems []string) string {
+	return fmt.Sprintf("[%s]", strings.Join(elems, ", "))
+}
+
+func (TypeScriptEmitter) Map(key, elem string) string {
+	return fmt.Sprintf("{ [key in (%s)]: (%s) }", key, elem)
+}
+
+func (TypeScriptEmitter) Nullable(typ string) string {
+	if isNullable(typ) {
+		return typ
+	}
+
+	return fmt.Sprintf("%s | null", typ)
+}
+
+func (e TypeScriptEmitter) Object(fields []Field) string {
+	terminator := e.terminator
+	if terminator == "" {
+		terminator = ";"
+	}
+
+	return objectType(fields, terminator)
+}
+
+func (TypeScriptEmitter) Reference(name string) string {
+	return name
+}
+
+func (TypeScriptEmitter) Custom(ts string) string {
+	return ts
+}
+
+func (TypeScriptEmitter) Declare(d Declaration) string {
+	return typeScriptDecl(d)
+}
@@ -0,0 +1,338 @@
+package tsreflect
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A jsonField is a struct field as it is marshaled by encoding/json.
+type jsonField struct {
+	reflect.StructField
+
+	name   string
+	tagged bool
+	index  []int
+	tag    tag
+
+	// promoted is set for fields promoted through an embedded pointer, which
+	// are left out when the pointer is nil.
+	promoted bool
+
+	// omitted is set for fields tagged `json:"-"` that are included with
+	// WithIncludeOmitted.
+	omitted bool
+
+	// owner is the struct that declares the field.
+	owner reflect.Type
+}
+
+// A tag is a parsed `json` struct tag, or a struct tag configured with
+// WithTagName.
+type tag struct {
+	name      string
+	omitempty bool
+	string    bool
+}
+
+// lookupTag returns the first of the struct tags `tags` set on `f`, falling
+// back to the `json` tag.
+func lookupTag(f reflect.StructField, tags []string) (string, bool) {
+	for _, name := range tags {
+		if tag, ok := f.Tag.Lookup(name); ok {
+			return tag, true
+		}
+	}
+
+	return f.Tag.Lookup("json")
+}
+
+func parseTag(f reflect.StructField, tags []string) (t tag) {
+	value, _ := lookupTag(f, tags)
+	name, opts, _ := strings.Cut(value, ",")
+
+	if isValidTag(name) {
+		t.name = name
+	}
+
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+
+		switch opt {
+		case "omitempty":
+			t.omitempty = true
+		case "string":
+			t.string = true
+		}
+	}
+
+	return
+}
+
+// A tsTag is a parsed `ts` struct tag, which overrides how a field is typed.
+type tsTag struct {
+	// typ is the TypeScript type of the field set with the `type=` option.
+	// It is the rest of the tag, so it can contain commas and has to be the
+	// last option (i.e. `ts:"type=Record<string, number>"`).
+	typ string
+
+	// readonly is set by the `readonly` option.
+	readonly bool
+}
+
+func parseTSTag(f reflect.StructField) (t tsTag) {
+	opts := f.Tag.Get("ts")
+
+	for opts != "" {
+		opts = strings.TrimLeft(opts, " ")
+
+		if typ, ok := strings.CutPrefix(opts, "type="); ok {
+			t.typ = strings.TrimSpace(typ)
+			break
+		}
+
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+
+		if strings.TrimSpace(opt) == "readonly" {
+			t.readonly = true
+		}
+	}
+
+	return
+}
+
+func newField(f reflect.StructField, index []int, tags []string) jsonField {
+	t := parseTag(f, tags)
+
+	name := t.name
+	if name == "" {
+		name = f.Name
+	}
+
+	return jsonField{
+		StructField: f,
+		name:        name,
+		tagged:      t.name != "",
+		index:       index,
+		tag:         t,
+	}
+}
+
+// jsonFields returns the fields of the struct `typ` in the order encoding/json
+// marshals them. Like encoding/json the fields of embedded structs are
+// promoted breadth first, and fields with conflicting names are resolved by
+// depth and tags or dropped. The struct tags `tags` are used before `json`.
+func jsonFields(typ reflect.Type, tags []string) []jsonField {
+	type embedded struct {
+		typ      reflect.Type
+		index    []int
+		promoted bool
+	}
+
+	var fields []jsonField
+
+	current := []embedded{}
+	next := []embedded{{typ: typ}}
+
+	var count, nextCount map[reflect.Type]int
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+
+				if isIgnoredField(sf, tags) {
+					continue
+				}
+
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				f := newField(sf, index, tags)
+				f.promoted = e.promoted
+				f.owner = e.typ
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+
+				if f.tagged || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					fields = append(fields, f)
+
+					if count[e.typ] > 1 {
+						// The same struct was embedded more than once at this
+						// depth, add a duplicate so its fields conflict.
+						fields = append(fields, f)
+					}
+
+					continue
+				}
+
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, embedded{
+						typ:      ft,
+						index:    index,
+						promoted: e.promoted || sf.Type.Kind() == reflect.Pointer,
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+
+		if x[i].name != x[j].name {
+			return x[i].name < x[j].name
+		}
+
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+
+		return lessIndex(x[i].index, x[j].index)
+	})
+
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		f := fields[i]
+
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != f.name {
+				break
+			}
+		}
+
+		if advance == 1 {
+			out = append(out, f)
+			continue
+		}
+
+		// The shallowest field wins, unless there is a tie in which case a
+		// single tagged field wins or all of them are dropped.
+		dominant := fields[i : i+advance]
+		if len(dominant[0].index) == len(dominant[1].index) && dominant[0].tagged == dominant[1].tagged {
+			continue
+		}
+
+		out = append(out, dominant[0])
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return lessIndex(out[i].index, out[j].index)
+	})
+
+	return out
+}
+
+func lessIndex(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+// isIgnoredField reports whether encoding/json ignores the struct field `f`.
+// Embedded structs are not ignored even when unexported since their exported
+// fields are promoted.
+func isIgnoredField(f reflect.StructField, tags []string) bool {
+	if hasTagOmit(f, tags) {
+		return true
+	}
+
+	if f.Anonymous {
+		t := f.Type
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		return !f.IsExported() && t.Kind() != reflect.Struct
+	}
+
+	return !f.IsExported()
+}
+
+func hasTagOmit(f reflect.StructField, tags []string) bool {
+	if tag, ok := lookupTag(f, tags); ok && tag == "-" {
+		return true
+	}
+
+	return false
+}
+
+// isValidTag reports whether `s` can be used as a name in a `json` struct tag,
+// encoding/json falls back to the field name for invalid names.
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+
+	return true
+}
+
+// isQuotable reports whether the `string` option of a `json` struct tag
+// applies to `typ`, that is to a scalar or a pointer to a scalar without a
+// custom marshaler.
+func isQuotable(typ reflect.Type) bool {
+	if typ.Name() == "" && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if typ.Implements(typeOfMarshaler) || reflect.PointerTo(typ).Implements(typeOfMarshaler) {
+		return false
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	default:
+		return false
+	}
+}
+
+// isOmittable reports whether a value of `typ` can be empty in the sense of
+// `omitempty`, encoding/json never omits structs and non-empty arrays.
+func isOmittable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Array:
+		return typ.Len() == 0
+	case reflect.Struct:
+		return false
+	default:
+		return true
+	}
+}
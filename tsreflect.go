@@ -3,36 +3,82 @@
 package tsreflect
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 )
 
 var (
 	typeOfMarshaler       = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeOfError           = reflect.TypeOf((*error)(nil)).Elem()
 	typeOfTypeScriptTyper = reflect.TypeOf((*TypeScriptTyper)(nil)).Elem()
+	typeOfTypeScriptNamer = reflect.TypeOf((*TypeScriptNamer)(nil)).Elem()
 	typeOfByteSlice       = reflect.TypeOf([]byte{})
 	typeOfTime            = reflect.TypeOf(time.Time{})
 	typeOfBigInt          = reflect.TypeOf(big.NewInt(0))
 )
 
+// Formats for byte slices used with WithBytesAs.
+const (
+	// BytesBase64String types byte slices as base64 strings that are null
+	// when nil, which is how encoding/json marshals them.
+	BytesBase64String = "base64string"
+	// BytesNonNullString types byte slices as base64 strings that are never
+	// null.
+	BytesNonNullString = "nonnullstring"
+	// BytesNumberArray types byte slices as arrays of numbers, for custom
+	// marshalers that do not base64 encode bytes.
+	BytesNumberArray = "numberarray"
+)
+
+// Policies for names returned by a namer that are taken, used with
+// WithNamerCollisionPolicy.
+const (
+	// NamerCollisionPanic panics, this is the default.
+	NamerCollisionPanic = "panic"
+	// NamerCollisionSuffix names the type sequentially like DefaultNamer
+	// (i.e. Name2).
+	NamerCollisionSuffix = "suffix"
+	// NamerCollisionError names the type sequentially and records an error
+	// that is returned by Err.
+	NamerCollisionError = "error"
+)
+
 // TypeScriptTyper is the interface implemented by types that can serialize
 // themselves into valid TypeScript types. The `optional` flag is used for
-// when a type is part of an optional field in an object.
+// when a type is part of an optional field in an object. Absence is already
+// expressed by the `?` marker of the field, so an optional type should only
+// describe present values: nil values that are omitted should not add `null`,
+// and `undefined` should never be added.
 type TypeScriptTyper interface {
 	TypeScriptType(g *Generator, optional bool) string
 }
 
+// TypeScriptNamer is the interface implemented by types that name their own
+// declaration. The name is used as is, without the namer, prefix or suffix of
+// the generator, and must be a valid identifier that is not taken. Methods
+// promoted from embedded fields are ignored, so a struct that embeds a
+// TypeScriptNamer is named by the namer.
+type TypeScriptNamer interface {
+	TypeScriptName() string
+}
+
 // A Typer is a function that can serialize types into valid TypeScript types.
 // The `optional` flag is used for when a type is part of an optional field in
-// an object.
+// an object, see TypeScriptTyper for how it should be handled. The generator
+// `g` is a view that can be used to render other types, see Generator.
 type Typer func(g *Generator, typ reflect.Type, optional bool) string
 
 // A Namer is a function that gives names to TypeScript types in a generator.
@@ -63,25 +109,129 @@ func sequentialNamer(name string, isNameTaken func(string) bool) string {
 	}
 }
 
-// A Declaration is a named TypeScript type.
+// A DeclarationKind is the kind of a TypeScript declaration.
+type DeclarationKind int
+
+const (
+	// InterfaceDeclaration is an object type declared as `interface Name {}`.
+	InterfaceDeclaration DeclarationKind = iota
+	// AliasDeclaration is a type declared as `type Name = ...`.
+	AliasDeclaration
+	// ConstEnumDeclaration is an enum declared as `const enum Name {}`.
+	ConstEnumDeclaration
+)
+
+func (k DeclarationKind) String() string {
+	switch k {
+	case InterfaceDeclaration:
+		return "interface"
+	case AliasDeclaration:
+		return "alias"
+	case ConstEnumDeclaration:
+		return "const enum"
+	default:
+		return fmt.Sprintf("DeclarationKind(%d)", int(k))
+	}
+}
+
+// A Declaration is a named TypeScript type, generic declarations have the
+// names of their type parameters in Params. Exported declarations are written
+// with the `export` keyword.
 type Declaration struct {
-	Name string
-	Type string
+	Name     string
+	Type     string
+	Kind     DeclarationKind
+	Params   []string
+	Exported bool
 }
 
+// Export modes of a generator.
+const (
+	exportNone = iota
+	exportAll
+	exportRoots
+)
+
 // A Generator is a generator of TypeScript types and declarations for Go types
-// that can be marshaled with `encoding/json`.
+// that can be marshaled with `encoding/json`. A Generator is safe for
+// concurrent use. Typers are called while it is locked and are passed a view
+// of it that renders types without locking, so they can call TypeOf but must
+// not add types to it or be kept after they return. Hooks and visitors must
+// not call it.
 type Generator struct {
-	flatten  bool
-	warnings bool
-	warn     func(string, ...any)
-	namer    Namer
-
-	typers   map[reflect.Type]Typer
-	types    map[reflect.Type]struct{}
-	circular map[reflect.Type]struct{}
-	symbols  map[reflect.Type]string
-	names    map[string]reflect.Type
+	// mu is nil for views, which are not locked.
+	mu *sync.RWMutex
+
+	// at is the scope a view renders types in.
+	at scope
+
+	flatten               bool
+	brandedStrings        bool
+	brandedUnsigned       bool
+	brandedTime           bool
+	omitemptyNullable     bool
+	nonNullCollections    bool
+	maximalNullability    bool
+	compactNull           bool
+	defensiveOptionals    bool
+	allOptional           bool
+	defaultTagOptional    bool
+	optionalPointerParams bool
+	readonlyTuples        bool
+	readonlySlices        bool
+	readonlyProps         bool
+	includeOmitted        bool
+	emptyRecords          bool
+	jsonValues            bool
+	errorResults          bool
+	dottedNesting         bool
+	typeAliases           bool
+	typeOnlyExports       bool
+	declareGlobal         bool
+	pruneUnused           bool
+	topologicalOrder      bool
+	sourceReferences      bool
+	schemaHash            bool
+	precisionWarnings     bool
+	warnings              bool
+	exportMode            int
+	maxDepth              int
+	fieldTerminator       string
+	streamType            string
+	namePrefix            string
+	nameSuffix            string
+	tagNames              []string
+	collisionPolicy       string
+	namer                 Namer
+	warn                  func(string, ...any)
+	warned                *seenWarnings
+	declarationHook       func(Declaration) Declaration
+	typeRewriter          func(*Generator, reflect.Type, string) string
+	typeVisitor           func(reflect.Type, []string)
+	errs                  []error
+
+	// The names of the aliases that are declared when they are first used.
+	valueName string
+	uintBrand string
+	timeBrand string
+
+	typers        map[reflect.Type]Typer
+	kindTypers    map[reflect.Kind]Typer
+	generics      map[reflect.Type]generic
+	concreteProps map[string]map[string]bool
+	aliases       map[string]func(s scope) string
+	named         map[reflect.Type]string
+	enums         map[reflect.Type]string
+	consts        map[constField]string
+	constEnums    map[reflect.Type][]EnumMember
+	unions        map[reflect.Type]union
+	roots         []reflect.Type
+	types         map[reflect.Type]struct{}
+	circular      map[reflect.Type]struct{}
+	inline        map[reflect.Type]bool
+	symbols       map[reflect.Type]string
+	names         map[string]reflect.Type
+	imports       map[string]struct{}
 }
 
 // An Option is a generator option.
@@ -94,6 +244,36 @@ func WithNamer(namer Namer) Option {
 	}
 }
 
+// WithNamerCollisionPolicy sets what happens when the namer returns a name
+// that is taken, `policy` is one of NamerCollisionPanic, NamerCollisionSuffix
+// or NamerCollisionError.
+func WithNamerCollisionPolicy(policy string) Option {
+	return func(g *Generator) {
+		switch policy {
+		case NamerCollisionPanic, NamerCollisionSuffix, NamerCollisionError:
+			g.collisionPolicy = policy
+		default:
+			panic(fmt.Sprintf("tsreflect: unknown namer collision policy %q", policy))
+		}
+	}
+}
+
+// WithNamePrefix adds `prefix` to the names returned by the namer (i.e. User
+// becomes ApiUser).
+func WithNamePrefix(prefix string) Option {
+	return func(g *Generator) {
+		g.namePrefix = prefix
+	}
+}
+
+// WithNameSuffix adds `suffix` to the names returned by the namer (i.e. User
+// becomes UserDTO).
+func WithNameSuffix(suffix string) Option {
+	return func(g *Generator) {
+		g.nameSuffix = suffix
+	}
+}
+
 // WithFlatten makes the generator flatten output types, minimizing the number
 // of required top-level declarations.
 func WithFlatten() Option {
@@ -102,375 +282,2599 @@ func WithFlatten() Option {
 	}
 }
 
-// WithNoWarnings suppress warnings.
-func WithNoWarnings() Option {
+// WithOmitemptyNullable makes optional pointer fields nullable, for APIs that
+// send an explicit `null` instead of omitting the field.
+func WithOmitemptyNullable() Option {
 	return func(g *Generator) {
-		g.warnings = false
+		g.omitemptyNullable = true
 	}
 }
 
-// WithTyper adds a Typer function for `typ`. This is needed for external types
-// that have custom MarshalJSON methods but do not implement the TypeScriptTyper
-// interface.
-func WithTyper(typ reflect.Type, typer Typer) Option {
+// WithNonNullCollections types slices and maps without `null`, for when nil
+// collections are never marshaled.
+func WithNonNullCollections() Option {
 	return func(g *Generator) {
-		g.typers[typ] = typer
+		g.nonNullCollections = true
 	}
 }
 
-// New create a new generator with options.
-func New(options ...Option) *Generator {
-	g := &Generator{
-		warnings: true,
-		warn:     log.Printf,
-		typers: map[reflect.Type]Typer{
-			typeOfByteSlice: func(g *Generator, t reflect.Type, optional bool) string {
-				if optional {
-					return "string"
-				}
-
-				return "(string | null)"
-			},
-			typeOfTime: func(g *Generator, t reflect.Type, optional bool) string {
-				return "string"
-			},
-			typeOfBigInt: func(g *Generator, t reflect.Type, optional bool) string {
-				if optional {
-					return "number"
-				}
+// WithPruneUnused leaves out declarations that are not referenced by name from
+// the added types, such as types that are only reached through a custom typer.
+// References made by typers are only seen if they render the types with
+// TypeOf, so other types they refer to should be added explicitly.
+func WithPruneUnused() Option {
+	return func(g *Generator) {
+		g.pruneUnused = true
+	}
+}
 
-				return "(number | null)"
-			},
-		},
-		types:    make(map[reflect.Type]struct{}),
-		circular: make(map[reflect.Type]struct{}),
-		symbols:  make(map[reflect.Type]string),
-		names:    make(map[string]reflect.Type),
+// WithReadonlyFixedArrays types arrays as readonly tuples (i.e. readonly
+// [number, number]) since their length is fixed, slices are left mutable.
+func WithReadonlyFixedArrays() Option {
+	return func(g *Generator) {
+		g.readonlyTuples = true
 	}
+}
 
-	g.namer = DefaultNamer
+// WithReadonlyArrays types slices as readonly arrays (i.e. readonly number[]),
+// for responses that should not be mutated.
+func WithReadonlyArrays() Option {
+	return func(g *Generator) {
+		g.readonlySlices = true
+	}
+}
 
-	for _, option := range options {
-		option(g)
+// WithBrandedUnsigned types unsigned integers as the branded number type Uint
+// (i.e. type Uint = number & { __uint: void }), so that numbers have to be
+// checked to be non-negative before they are used as unsigned integers.
+func WithBrandedUnsigned() Option {
+	return func(g *Generator) {
+		g.brandedUnsigned = true
 	}
+}
 
-	return g
+// WithErrorAsResult types funcs that return an error as returning a result
+// object (i.e. { "data": number | null; "error": string | null; }), for
+// bridges that send the error to the caller instead of dropping it.
+func WithErrorAsResult() Option {
+	return func(g *Generator) {
+		g.errorResults = true
+	}
 }
 
-// Add add a type to the generator.
-func (g *Generator) Add(typ reflect.Type) {
-	g.add(typ, nil)
+// WithOptionalPointerParams types trailing pointer parameters of funcs as
+// optional (i.e. arg1?: Options), since callers often leave them out. Pointer
+// parameters followed by a non-pointer parameter can not be optional and are
+// warned about.
+func WithOptionalPointerParams() Option {
+	return func(g *Generator) {
+		g.optionalPointerParams = true
+	}
 }
 
-// TypeOf returns the TypeScript type for `typ`.
-func (g *Generator) TypeOf(typ reflect.Type) string {
-	return g.typeOf(typ, false)
+// WithExport exports every declaration.
+func WithExport() Option {
+	return func(g *Generator) {
+		g.exportMode = exportAll
+	}
 }
 
-// Declarations returns the required top-level declarations for the TypeScript
-// types in the generator.
-func (g *Generator) Declarations() (ds []Declaration) {
-	names := make([]string, 0, len(g.symbols))
-	for _, name := range g.symbols {
-		names = append(names, name)
+// WithExportRoots exports only the declarations of the types that are added to
+// the generator, the types they refer to are declared without being exported.
+func WithExportRoots() Option {
+	return func(g *Generator) {
+		g.exportMode = exportRoots
 	}
+}
 
-	sort.Strings(names)
+// WithTypeOnlyExports exports declarations with a single `export type { ... }`
+// after them instead of the `export` keyword, as required for re-exports with
+// `isolatedModules` or `verbatimModuleSyntax`. Const enums are not types only
+// and keep the `export` keyword.
+func WithTypeOnlyExports() Option {
+	return func(g *Generator) {
+		g.typeOnlyExports = true
+	}
+}
 
-	var sb strings.Builder
-	for _, name := range names {
-		typ := g.names[name]
+// WithReadonly makes every struct property readonly (i.e. readonly "name":
+// T), for types that should not be mutated. Single fields can be made readonly
+// with the `readonly` option of the `ts` struct tag (i.e. `ts:"readonly"`).
+func WithReadonly() Option {
+	return func(g *Generator) {
+		g.readonlyProps = true
+	}
+}
 
-		if _, ok := g.circular[typ]; !ok && g.flatten {
-			continue
-		}
+// WithEmptyRecords types structs without fields as `Record<string, never>`
+// instead of `{ }`, named empty structs are declared as type aliases.
+func WithEmptyRecords() Option {
+	return func(g *Generator) {
+		g.emptyRecords = true
+	}
+}
 
-		if g.hasCustomType(typ) {
-			continue
-		}
+// WithDefaultTagOptional makes fields with a `default` struct tag optional,
+// for request types where the server fills in a default value for fields the
+// client leaves out. Fields that can not be omitted by encoding/json become
+// optional as well.
+func WithDefaultTagOptional() Option {
+	return func(g *Generator) {
+		g.defaultTagOptional = true
+	}
+}
 
-		g.writeStructDecl(&sb, typ)
+// WithIncludeOmitted includes exported fields tagged `json:"-"` as readonly
+// optional properties, so that the declarations document the full shape of the
+// Go structs. The fields are never marshaled.
+func WithIncludeOmitted() Option {
+	return func(g *Generator) {
+		g.includeOmitted = true
+	}
+}
 
-		ds = append(ds, Declaration{
-			Name: name,
-			Type: sb.String(),
-		})
+// WithTypeAliases declares structs as type aliases (i.e. type User = { ... })
+// instead of interfaces. Unlike interfaces type aliases can not be merged.
+func WithTypeAliases() Option {
+	return func(g *Generator) {
+		g.typeAliases = true
+	}
+}
 
-		sb.Reset()
+// WithPrecisionWarnings warns of 64-bit integers typed as `number`, which can
+// not represent integers beyond 2^53 exactly.
+func WithPrecisionWarnings() Option {
+	return func(g *Generator) {
+		g.precisionWarnings = true
 	}
+}
 
-	return
+// WithSchemaHashHeader starts the declarations with a `// schema: <hash>`
+// comment holding the SchemaHash of the generator, so that generated files can
+// be compared to detect changes to the types.
+func WithSchemaHashHeader() Option {
+	return func(g *Generator) {
+		g.schemaHash = true
+	}
 }
 
-// DeclarationsTypeScript returns the required top-level declarations for the
-// TypeScript types in the generator as a TypeScript string.
-func (g *Generator) DeclarationsTypeScript() string {
-	return g.declarations(false)
+// WithTopologicalOrder orders declarations so that they come after the
+// declarations they refer to instead of by name, for output where types must
+// be defined before they are used.
+func WithTopologicalOrder() Option {
+	return func(g *Generator) {
+		g.topologicalOrder = true
+	}
 }
 
-// DeclarationsJSDoc returns the required top-level declarations for the
-// TypeScript types in the generator as a JSDoc string.
-func (g *Generator) DeclarationsJSDoc() string {
-	return g.declarations(true)
+// WithSourceReference adds a `@see` comment with the package qualified name
+// of the Go type to every declaration (i.e. @see github.com/me/pkg.User).
+func WithSourceReference() Option {
+	return func(g *Generator) {
+		g.sourceReferences = true
+	}
 }
 
-func (g *Generator) add(typ reflect.Type, parent reflect.Type) bool {
-	if typ == nil {
-		return false
+// WithMaximalNullability makes every pointer field both optional and nullable
+// (i.e. "field"?: T | null), for consuming APIs that may omit a field or
+// send `null` regardless of how it is declared.
+func WithMaximalNullability() Option {
+	return func(g *Generator) {
+		g.maximalNullability = true
 	}
+}
 
-	if _, ok := g.types[typ]; ok {
-		return typ == parent
+// WithReadableStreams declares the streams added with AddStream as
+// `ReadableStream<T>` instead of `AsyncIterable<T>`.
+func WithReadableStreams() Option {
+	return func(g *Generator) {
+		g.streamType = "ReadableStream"
 	}
+}
 
-	g.types[typ] = struct{}{}
+// WithDeclareGlobal wraps the TypeScript declarations in `declare global {}`
+// followed by `export {}`, which adds them to the global scope (i.e. for types
+// of globals injected into `window`). JSDoc declarations are not wrapped.
+func WithDeclareGlobal() Option {
+	return func(g *Generator) {
+		g.declareGlobal = true
+	}
+}
 
-	switch typ.Kind() {
-	case reflect.Array:
-		return g.add(typ.Elem(), parent)
-	case reflect.Slice:
-		return g.add(typ.Elem(), parent)
-	case reflect.Map:
-		return g.add(typ.Key(), parent) || g.add(typ.Elem(), parent)
-	case reflect.Pointer:
-		return g.add(typ.Elem(), parent)
-	case reflect.Struct:
-		hasName := typ.Name() != ""
-		hasExportedFields := countExportedFields(typ) > 0
+// WithJSONValueType types interfaces as the recursive type JSONValue of any
+// JSON value instead of `any` (i.e. type JSONValue = string | number | boolean
+// | null | JSONValue[] | { [key: string]: JSONValue }).
+func WithJSONValueType() Option {
+	return func(g *Generator) {
+		g.jsonValues = true
+	}
+}
 
-		isCircular := false
-		for i := 0; i < typ.NumField(); i++ {
-			f := typ.Field(i)
+// WithAllFieldsOptional makes every field optional (i.e. "field"?: T) without
+// changing its type, for types of request bodies where clients may leave out
+// any field. AddPartial declares an optional copy of a single type instead.
+func WithAllFieldsOptional() Option {
+	return func(g *Generator) {
+		g.allOptional = true
+	}
+}
 
-			if !f.IsExported() || hasTagOmit(f) {
-				continue
-			}
+// WithCompactNull types nil values as absent instead of null, pointer, slice
+// and map fields are optional and never null (i.e. "field"?: T) and null is
+// left out of every type. This is for clients that do not tell null and
+// absent apart, since values with nil fields no longer match their types.
+func WithCompactNull() Option {
+	return func(g *Generator) {
+		g.compactNull = true
+	}
+}
 
-			if hasName {
-				isCircular = isCircular || g.add(f.Type, typ)
-			} else {
-				isCircular = isCircular || g.add(f.Type, parent)
-			}
-		}
+// WithDottedTagNesting types fields with dotted names as nested objects
+// (i.e. `json:"a.b"` as "a": { "b": T }), for codecs that marshal dotted
+// names as paths. encoding/json does not, it uses dotted names as is. Dotted
+// names that would nest in another property (i.e. "a.b" next to "a") are left
+// as is with a warning.
+func WithDottedTagNesting() Option {
+	return func(g *Generator) {
+		g.dottedNesting = true
+	}
+}
 
-		if isCircular {
-			g.circular[typ] = struct{}{}
-		}
+// WithDefensiveOptionals types optional pointer fields as T | null |
+// undefined and other pointer fields as T | null, for consuming APIs that
+// omit fields and send null inconsistently.
+func WithDefensiveOptionals() Option {
+	return func(g *Generator) {
+		g.defensiveOptionals = true
+	}
+}
 
-		if hasName && hasExportedFields {
-			name := g.namer(typ, g.isNameTaken)
+// WithTypeVisitor sets a function that is called once with every type that
+// is added to the generator and the path to it from the added type. The path
+// holds the names of struct fields and [] for elements of arrays and slices,
+// {key} and {} for keys and values of maps and () for params and results of
+// funcs (i.e. [Users [] Name]). The visitor is called while types are added
+// and must not call the generator.
+func WithTypeVisitor(visitor func(typ reflect.Type, path []string)) Option {
+	return func(g *Generator) {
+		g.typeVisitor = visitor
+	}
+}
 
-			if g.isNameTaken(name) {
-				panic(fmt.Sprintf("tsreflect: namer returned taken name %q", name))
+// WithTimeAsBranded types time.Time as the branded string type ISODateString
+// (i.e. type ISODateString = string & { __iso: void }), so that arbitrary
+// strings can not be used as timestamps.
+func WithTimeAsBranded() Option {
+	return func(g *Generator) {
+		g.brandedTime = true
+		g.typers[typeOfTime] = func(g *Generator, t reflect.Type, optional bool) string {
+			if g.timeBrand == "" {
+				return "string"
 			}
 
-			g.symbols[typ] = name
-			g.names[name] = typ
+			return g.at.ref(g.timeBrand)
 		}
-
-		return false
-	default:
-		return false
 	}
 }
 
-func hasInterface(u reflect.Type, typ reflect.Type) bool {
-	if typ.Kind() == reflect.Pointer && typ.Implements(u) {
-		return !typ.Elem().Implements(u)
+// WithMaxDepth limits how deeply nested a type is rendered, types nested
+// deeper than `depth` are typed as `any` with a warning. Declared types start
+// at a depth of zero. The default is no limit.
+func WithMaxDepth(depth int) Option {
+	return func(g *Generator) {
+		g.maxDepth = depth
 	}
+}
 
-	return typ.Implements(u)
+// WithBrandedStrings declares named string types as branded types (i.e type
+// UserID = string & { readonly __brand: "UserID" }) so that they can not be
+// used in place of each other.
+func WithBrandedStrings() Option {
+	return func(g *Generator) {
+		g.brandedStrings = true
+	}
 }
 
-func (g *Generator) typeOf(typ reflect.Type, optional bool) string {
-	if typ == nil {
-		return "any"
+// WithTagName makes the generator read field names and options from the
+// struct tag `name` before the `json` tag. Multiple tag names are tried in the
+// order they are added.
+func WithTagName(name string) Option {
+	return func(g *Generator) {
+		g.tagNames = append(g.tagNames, name)
 	}
+}
 
-	if hasInterface(typeOfTypeScriptTyper, typ) {
-		t := reflect.New(typ).Elem().Interface().(TypeScriptTyper)
-		return t.TypeScriptType(g, optional)
+// WithNoWarnings suppress warnings.
+func WithNoWarnings() Option {
+	return func(g *Generator) {
+		g.warnings = false
 	}
+}
 
-	if typer, ok := g.typers[typ]; ok {
-		return typer(g, typ, optional)
+// WithDeclarationHook sets a function that is called with every declaration
+// before it is returned by Declarations, allowing it to be rewritten.
+func WithDeclarationHook(hook func(d Declaration) Declaration) Option {
+	return func(g *Generator) {
+		g.declarationHook = hook
 	}
+}
 
-	if hasInterface(typeOfMarshaler, typ) && g.warnings {
-		g.warn("tsreflect: WARNING json.Marshaler implemented for type %q but no corresponding typer could be found.", typ.Name())
+// WithTypeRewriter sets a function that is called with every type and its
+// TypeScript type `ts`, and returns the TypeScript type to use instead (i.e.
+// to type `any` as `unknown`). Like a Typer the rewriter is passed a view of
+// the generator, types rendered with it are not rewritten so the rewriter can
+// not recurse.
+func WithTypeRewriter(rewriter func(g *Generator, typ reflect.Type, ts string) string) Option {
+	return func(g *Generator) {
+		g.typeRewriter = rewriter
 	}
+}
 
-	switch typ.Kind() {
-	case reflect.Bool:
-		return "boolean"
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64:
-		return "number"
-	case reflect.String:
-		return "string"
-	case reflect.Array:
-		elem := g.typeOf(typ.Elem(), false)
+// WithBytesAs sets how byte slices are typed, `format` is one of
+// BytesBase64String (default), BytesNonNullString or BytesNumberArray.
+func WithBytesAs(format string) Option {
+	return func(g *Generator) {
+		switch format {
+		case BytesBase64String:
+			g.typers[typeOfByteSlice] = typeOfBase64String
+		case BytesNonNullString:
+			g.typers[typeOfByteSlice] = func(g *Generator, t reflect.Type, optional bool) string {
+				return "string"
+			}
+		case BytesNumberArray:
+			g.typers[typeOfByteSlice] = func(g *Generator, t reflect.Type, optional bool) string {
+				if optional {
+					return "number[]"
+				}
 
-		s := make([]string, typ.Len())
-		for i := range s {
-			s[i] = elem
+				return "number[] | null"
+			}
+		default:
+			panic(fmt.Sprintf("tsreflect: unknown bytes format %q", format))
 		}
+	}
+}
 
-		return fmt.Sprintf("[%s]", strings.Join(s, ", "))
-	case reflect.Slice:
-		if optional {
-			return fmt.Sprintf("%s[]", g.typeOf(typ.Elem(), false))
+// WithErrorAs types values of the `error` interface as `ts` (i.e. string for
+// errors marshaled as their message) instead of `any`.
+func WithErrorAs(ts string) Option {
+	return func(g *Generator) {
+		g.typers[typeOfError] = func(g *Generator, t reflect.Type, optional bool) string {
+			return ts
 		}
+	}
+}
 
-		return fmt.Sprintf("(%s[] | null)", g.typeOf(typ.Elem(), false))
-	case reflect.Map:
-		if optional {
-			return fmt.Sprintf("{ [key in (%s)]: (%s) }", g.typeOf(typ.Key(), false), g.typeOf(typ.Elem(), false))
-		}
+// WithArrayMarshaler adds a Typer for `typ` whose MarshalJSON method always
+// returns an array of `elem` (i.e. a slice that marshals nil as []).
+func WithArrayMarshaler(typ reflect.Type, elem string) Option {
+	return WithTyper(typ, func(g *Generator, t reflect.Type, optional bool) string {
+		return fmt.Sprintf("%s[]", group(elem))
+	})
+}
 
-		return fmt.Sprintf("({ [key in (%s)]: (%s) } | null)", g.typeOf(typ.Key(), false), g.typeOf(typ.Elem(), false))
-	case reflect.Pointer:
-		if optional {
-			return g.typeOf(typ.Elem(), false)
+// WithFieldTerminator sets the terminator written after every property of an
+// object type, `terminator` is either ";" (default) or ",".
+func WithFieldTerminator(terminator string) Option {
+	return func(g *Generator) {
+		if terminator != ";" && terminator != "," {
+			panic(fmt.Sprintf("tsreflect: unknown field terminator %q", terminator))
 		}
 
-		return fmt.Sprintf("(%s | null)", g.typeOf(typ.Elem(), false))
-	case reflect.Struct:
-		name := g.symbols[typ]
-		_, isCircular := g.circular[typ]
-
-		if name == "" || (!isCircular && g.flatten) {
-			var sb strings.Builder
-			g.writeStructDecl(&sb, typ)
-			return sb.String()
-		}
+		g.fieldTerminator = terminator
+	}
+}
 
-		return name
-	case reflect.Interface:
-		return "any"
-	default:
-		return ""
+// WithTyper adds a Typer function for `typ`. This is needed for external types
+// that have custom MarshalJSON methods but do not implement the TypeScriptTyper
+// interface.
+func WithTyper(typ reflect.Type, typer Typer) Option {
+	return func(g *Generator) {
+		g.typers[typ] = typer
 	}
 }
 
-func (g *Generator) declarations(jsDoc bool) string {
-	var sb strings.Builder
+// WithTyperForKind adds a Typer for every type of `kind` that does not have a
+// Typer of its own or implement the TypeScriptTyper interface.
+func WithTyperForKind(kind reflect.Kind, typer Typer) Option {
+	return func(g *Generator) {
+		g.kindTypers[kind] = typer
+	}
+}
 
-	decls := g.Declarations()
-	for i, decl := range decls {
+// New create a new generator with options.
+func New(options ...Option) *Generator {
+	g := &Generator{
+		mu:       new(sync.RWMutex),
+		warnings: true,
+		warn:     log.Printf,
+		warned:   &seenWarnings{seen: make(map[string]struct{})},
+		typers: map[reflect.Type]Typer{
+			typeOfByteSlice: typeOfBase64String,
+			typeOfTime: func(g *Generator, t reflect.Type, optional bool) string {
+				return "string"
+			},
+			typeOfBigInt: func(g *Generator, t reflect.Type, optional bool) string {
+				if optional {
+					return "number"
+				}
+
+				return "number | null"
+			},
+		},
+		kindTypers:    make(map[reflect.Kind]Typer),
+		generics:      make(map[reflect.Type]generic),
+		concreteProps: make(map[string]map[string]bool),
+		aliases:       make(map[string]func(s scope) string),
+		named:         make(map[reflect.Type]string),
+		enums:         make(map[reflect.Type]string),
+		consts:        make(map[constField]string),
+		constEnums:    make(map[reflect.Type][]EnumMember),
+		unions:        make(map[reflect.Type]union),
+		types:         make(map[reflect.Type]struct{}),
+		circular:      make(map[reflect.Type]struct{}),
+		inline:        make(map[reflect.Type]bool),
+		symbols:       make(map[reflect.Type]string),
+		imports:       make(map[string]struct{}),
+		names:         make(map[string]reflect.Type),
+	}
+
+	g.namer = DefaultNamer
+	g.collisionPolicy = NamerCollisionPanic
+	g.fieldTerminator = ";"
+	g.streamType = "AsyncIterable"
+
+	for _, option := range options {
+		option(g)
+	}
+
+	return g
+}
+
+func typeOfBase64String(g *Generator, t reflect.Type, optional bool) string {
+	if optional {
+		return "string"
+	}
+
+	return "string | null"
+}
+
+// lock locks the generator for writing, views can not be written.
+func (g *Generator) lock() {
+	if g.mu == nil {
+		panic("tsreflect: generator is changed by a typer")
+	}
+
+	g.mu.Lock()
+}
+
+func (g *Generator) unlock() {
+	g.mu.Unlock()
+}
+
+// rlock locks the generator for reading, views are read without locking since
+// they are used while the generator is locked.
+func (g *Generator) rlock() {
+	if g.mu != nil {
+		g.mu.RLock()
+	}
+}
+
+func (g *Generator) runlock() {
+	if g.mu != nil {
+		g.mu.RUnlock()
+	}
+}
+
+// view returns a copy of the generator that is passed to typers while it is
+// locked, its methods do not lock and render types in the scope `s`.
+func (g *Generator) view(s scope) *Generator {
+	v := *g
+	v.mu = nil
+	v.at = s
+
+	return &v
+}
+
+// Err returns the errors recorded by the generator, such as names that were
+// taken with NamerCollisionError, or nil if there are none.
+func (g *Generator) Err() error {
+	g.rlock()
+	defer g.runlock()
+
+	return errors.Join(g.errs...)
+}
+
+// Add add a type to the generator.
+func (g *Generator) Add(typ reflect.Type) {
+	g.lock()
+	defer g.unlock()
+
+	g.addRoot(typ)
+}
+
+// addRoot adds `typ` as a type that is added to the generator.
+func (g *Generator) addRoot(typ reflect.Type) {
+	if _, ok := g.types[typ]; !ok && typ != nil {
+		g.roots = append(g.roots, typ)
+	}
+
+	g.add(typ, nil, nil)
+}
+
+// Add adds the type `T` to the generator `g`.
+func Add[T any](g *Generator) {
+	g.Add(reflect.TypeFor[T]())
+}
+
+// TypeOf returns the TypeScript type for `T`.
+func TypeOf[T any](g *Generator) string {
+	return g.TypeOf(reflect.TypeFor[T]())
+}
+
+// TypeOfField returns the TypeScript type for the struct field `f`, taking its
+// struct tags into account. Optional fields are typed without `null`.
+func (g *Generator) TypeOfField(f reflect.StructField) string {
+	g.rlock()
+	defer g.runlock()
+
+	return fieldType(g.field(g.at, newField(f, f.Index, g.tagNames)))
+}
+
+// SetInline overrides the flatten setting of the generator for `typ`. An
+// inlined type is written out in full wherever it is used instead of being
+// declared, circular types are always declared.
+func (g *Generator) SetInline(typ reflect.Type, inline bool) {
+	g.lock()
+	defer g.unlock()
+
+	g.inline[typ] = inline
+}
+
+// A generic is an instantiation of a generic type.
+type generic struct {
+	base   string
+	params []reflect.Type
+}
+
+// paramNames returns the names of the type parameters of the generic type, T
+// for a single parameter and T1, T2 ... otherwise.
+func (gen generic) paramNames() []string {
+	if len(gen.params) == 1 {
+		return []string{"T"}
+	}
+
+	names := make([]string, len(gen.params))
+	for i := range names {
+		names[i] = fmt.Sprintf("T%d", i+1)
+	}
+
+	return names
+}
+
+func (gen generic) paramMap() map[reflect.Type]string {
+	params := make(map[reflect.Type]string)
+	for i, name := range gen.paramNames() {
+		params[gen.params[i]] = name
+	}
+
+	return params
+}
+
+// RegisterGeneric registers the struct `instantiated` as an instantiation of
+// the generic type `base` with the type arguments `params`, (i.e Page[User]
+// as Page<User>). The generic type is declared once using the first
+// registered instantiation, where every use of a type argument is replaced by
+// its type parameter. A field whose type is a type argument can not be told
+// apart from a use of it (i.e. Next int in Page[int]), until a second
+// instantiation with different type arguments is registered, after which the
+// fields that have the same type in both are typed as is.
+func (g *Generator) RegisterGeneric(instantiated reflect.Type, base string, params ...reflect.Type) {
+	g.lock()
+	defer g.unlock()
+
+	if instantiated.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("tsreflect: type %q is not a struct", instantiated.String()))
+	}
+
+	if typ, ok := g.names[base]; ok && g.generics[typ].base != base {
+		panic(fmt.Sprintf("tsreflect: generic type name %q is taken", base))
+	}
+
+	if name, ok := g.symbols[instantiated]; ok {
+		delete(g.symbols, instantiated)
+		delete(g.names, name)
+	}
+
+	g.generics[instantiated] = generic{
+		base:   base,
+		params: params,
+	}
+
+	if first, ok := g.names[base]; !ok {
+		g.names[base] = instantiated
+	} else if _, ok := g.concreteProps[base]; !ok && first != instantiated {
+		g.resolveConcrete(base, first, instantiated)
+	}
+
+	g.add(instantiated, nil, nil)
+
+	for _, param := range params {
+		g.add(param, nil, nil)
+	}
+}
+
+// resolveConcrete records the properties of the generic type `base` that do
+// not depend on its type parameters, which are the properties that have the
+// same type in the instantiations `a` and `b` if every type argument differs.
+func (g *Generator) resolveConcrete(base string, a, b reflect.Type) {
+	genA, genB := g.generics[a], g.generics[b]
+
+	if len(genA.params) != len(genB.params) {
+		return
+	}
+
+	for i := range genA.params {
+		if genA.params[i] == genB.params[i] {
+			return
+		}
+	}
+
+	types := make(map[string]reflect.Type)
+	for _, f := range jsonFields(b, g.tagNames) {
+		types[f.name] = f.Type
+	}
+
+	concrete := make(map[string]bool)
+	for _, f := range jsonFields(a, g.tagNames) {
+		if types[f.name] == f.Type {
+			concrete[f.name] = true
+		}
+	}
+
+	g.concreteProps[base] = concrete
+}
+
+// genericScope returns the scope `s` of the declaration of the generic type
+// of `gen`.
+func (g *Generator) genericScope(s scope, gen generic) scope {
+	s.params = gen.paramMap()
+	s.concrete = g.concreteProps[gen.base]
+
+	return s
+}
+
+// A constField is a struct field registered with RegisterConstField.
+type constField struct {
+	typ  reflect.Type
+	name string
+}
+
+// RegisterConstField types the field `name` of the struct `typ` as the JSON
+// literal of `value` (i.e. "kind": "user"), for fields that always hold the
+// same value such as discriminators.
+func (g *Generator) RegisterConstField(typ reflect.Type, name string, value any) {
+	g.lock()
+	defer g.unlock()
+
+	var f reflect.StructField
+
+	ok := false
+	if typ.Kind() == reflect.Struct {
+		f, ok = typ.FieldByName(name)
+	}
+
+	if !ok || len(f.Index) != 1 {
+		panic(fmt.Sprintf("tsreflect: type %q has no field %q", typ.String(), name))
+	}
+
+	bs, err := json.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("tsreflect: value %v of field %q can not be marshaled: %s", value, name, err))
+	}
+
+	g.consts[constField{typ, name}] = string(bs)
+}
+
+// An EnumMember is a named numeric value of a const enum.
+type EnumMember struct {
+	Name  string
+	Value int
+}
+
+// RegisterConstEnum declares the named type `typ` as a const enum of
+// `members` (i.e. const enum Status { Active = 0, Inactive = 1 }). Const enums
+// have no runtime object and are inlined by the TypeScript compiler, so they
+// can not be used across files when `isolatedModules` is enabled.
+func (g *Generator) RegisterConstEnum(typ reflect.Type, members ...EnumMember) {
+	g.lock()
+	defer g.unlock()
+
+	if typ.PkgPath() == "" {
+		panic(fmt.Sprintf("tsreflect: const enum type %q is not named", typ.String()))
+	}
+
+	values := make([]any, len(members))
+	for i, member := range members {
+		if !isIdentifier(member.Name) {
+			panic(fmt.Sprintf("tsreflect: const enum member %q of %q is not a valid identifier", member.Name, typ.String()))
+		}
+
+		values[i] = member.Value
+	}
+
+	g.registerEnum(typ, values...)
+	g.constEnums[typ] = members
+}
+
+func constEnumBody(members []EnumMember) string {
+	var sb strings.Builder
+
+	sb.WriteString("{ ")
+
+	for i, member := range members {
+		sb.WriteString(fmt.Sprintf("%s = %d", member.Name, member.Value))
+
+		if i < len(members)-1 {
+			sb.WriteString(",")
+		}
+
+		sb.WriteString(" ")
+	}
+
+	sb.WriteString("}")
+
+	return sb.String()
+}
+
+// RegisterOpaque types `typ` as a freeform object, `Record<string, unknown>`,
+// regardless of its fields. This is useful for types such as configuration or
+// metadata that are marshaled as arbitrary objects.
+func (g *Generator) RegisterOpaque(typ reflect.Type) {
+	g.lock()
+	defer g.unlock()
+
+	g.typers[typ] = func(g *Generator, t reflect.Type, optional bool) string {
+		return "Record<string, unknown>"
+	}
+}
+
+// RegisterEnum types `typ` as the union of the JSON values `values` (i.e.
+// "active" | "inactive" or true), named types are declared as a type alias of
+// the union.
+func (g *Generator) RegisterEnum(typ reflect.Type, values ...any) {
+	g.lock()
+	defer g.unlock()
+
+	g.registerEnum(typ, values...)
+}
+
+func (g *Generator) registerEnum(typ reflect.Type, values ...any) {
+	literals := make([]string, len(values))
+	for i, value := range values {
+		bs, err := json.Marshal(value)
+		if err != nil {
+			panic(fmt.Sprintf("tsreflect: enum value %v of %q can not be marshaled: %s", value, typ.String(), err))
+		}
+
+		literals[i] = string(bs)
+	}
+
+	g.enums[typ] = strings.Join(literals, " | ")
+	g.types[typ] = struct{}{}
+
+	if _, ok := g.symbols[typ]; !ok && typ.PkgPath() != "" {
+		g.register(typ)
+	}
+}
+
+// AddPartial adds `typ` to the generator together with a declaration `name`
+// of it where every property is optional (i.e type UserUpdate = Partial<User>).
+func (g *Generator) AddPartial(typ reflect.Type, name string) {
+	g.lock()
+	defer g.unlock()
+
+	g.addRoot(typ)
+
+	g.alias(name, func(s scope) string {
+		return fmt.Sprintf("Partial<%s>", g.typeOf(s, typ, true))
+	})
+}
+
+// Import makes the generator refer to the types declared by `other` by the
+// same names without declaring them, so that the types of packages that depend
+// on each other can be generated separately. Import should be called before
+// any types are added.
+func (g *Generator) Import(other *Generator) {
+	g.lock()
+	defer g.unlock()
+
+	other.rlock()
+	defer other.runlock()
+
+	for _, d := range other.declarationList() {
+		g.names[d.Name] = other.names[d.Name]
+		g.imports[d.Name] = struct{}{}
+	}
+
+	for typ, name := range other.symbols {
+		if _, ok := g.imports[name]; ok {
+			g.symbols[typ] = name
+			g.types[typ] = struct{}{}
+		}
+	}
+
+	for typ, gen := range other.generics {
+		if _, ok := g.imports[gen.base]; ok {
+			g.generics[typ] = gen
+			g.types[typ] = struct{}{}
+		}
+	}
+
+	for typ, name := range other.named {
+		if _, ok := g.imports[name]; ok {
+			g.named[typ] = name
+			g.types[typ] = struct{}{}
+		}
+	}
+
+	for typ, union := range other.enums {
+		if _, ok := g.imports[other.symbols[typ]]; ok {
+			g.enums[typ] = union
+		}
+	}
+
+	for typ, members := range other.constEnums {
+		if _, ok := g.imports[other.symbols[typ]]; ok {
+			g.constEnums[typ] = members
+		}
+	}
+
+	// The aliases that are declared when they are first used are referred to
+	// by their imported names if the generator would declare them too.
+	if _, ok := g.imports[other.timeBrand]; ok && g.brandedTime && g.timeBrand == "" {
+		g.timeBrand = other.timeBrand
+	}
+
+	if _, ok := g.imports[other.uintBrand]; ok && g.brandedUnsigned && g.uintBrand == "" {
+		g.uintBrand = other.uintBrand
+	}
+
+	if _, ok := g.imports[other.valueName]; ok && g.jsonValues && g.valueName == "" {
+		g.valueName = other.valueName
+	}
+}
+
+// AddNamed adds `typ` to the generator together with a declaration `name` of
+// it, and refers to `typ` by that name. This is meant for types without a name
+// such as maps and slices (i.e type UserMap = { [key in (string)]: (User) }).
+// The declaration is typed without null, which is added where it is used
+// (i.e. "users": UserMap | null).
+func (g *Generator) AddNamed(typ reflect.Type, name string) {
+	g.lock()
+	defer g.unlock()
+
+	g.alias(name, func(s scope) string {
+		s.decl = typ
+		return g.typeOf(s, typ, true)
+	})
+
+	g.addRoot(typ)
+	g.named[typ] = name
+}
+
+// AddOmit adds `typ` to the generator together with a declaration `name` of
+// it without the properties `fields` (i.e type PublicUser = Omit<User,
+// "password">).
+func (g *Generator) AddOmit(typ reflect.Type, name string, fields ...string) {
+	g.lock()
+	defer g.unlock()
+
+	g.addUtility("Omit", typ, name, fields)
+}
+
+// AddPick adds `typ` to the generator together with a declaration `name` of
+// it with only the properties `fields` (i.e type UserName = Pick<User,
+// "name">).
+func (g *Generator) AddPick(typ reflect.Type, name string, fields ...string) {
+	g.lock()
+	defer g.unlock()
+
+	g.addUtility("Pick", typ, name, fields)
+}
+
+// addUtility declares `name` as the utility type `utility` of `typ` and the
+// properties `fields`.
+func (g *Generator) addUtility(utility string, typ reflect.Type, name string, fields []string) {
+	st := typ
+	if st.Kind() == reflect.Pointer {
+		st = st.Elem()
+	}
+
+	if st.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("tsreflect: type %q is not a struct", typ.String()))
+	}
+
+	props := make(map[string]bool)
+	for _, f := range jsonFields(st, g.tagNames) {
+		props[f.name] = true
+	}
+
+	keys := make([]string, len(fields))
+	for i, field := range fields {
+		if !props[field] {
+			panic(fmt.Sprintf("tsreflect: type %q has no property %q", typ.String(), field))
+		}
+
+		keys[i] = strconv.Quote(field)
+	}
+
+	union := strings.Join(keys, " | ")
+	if union == "" {
+		union = "never"
+	}
+
+	g.addRoot(typ)
+
+	g.alias(name, func(s scope) string {
+		return fmt.Sprintf("%s<%s, %s>", utility, g.typeOf(s, typ, true), union)
+	})
+}
+
+// AddMessageUnion adds `types` to the generator together with a declaration
+// `name` of their union (i.e type Message = Joined | Left), for messages that
+// are told apart by their shape or a field registered with
+// RegisterConstField.
+func (g *Generator) AddMessageUnion(name string, types ...reflect.Type) {
+	g.lock()
+	defer g.unlock()
+
+	for _, typ := range types {
+		g.addRoot(typ)
+	}
+
+	g.alias(name, func(s scope) string {
+		members := make([]string, len(types))
+		for i, typ := range types {
+			members[i] = g.typeOf(s, typ, true)
+		}
+
+		if len(members) == 0 {
+			return "never"
+		}
+
+		return strings.Join(members, " | ")
+	})
+}
+
+// A union is an interface added with AddUnion.
+type union struct {
+	impls         []reflect.Type
+	discriminator string
+}
+
+// AddUnion adds the structs `impls` that implement the interface `iface` to
+// the generator, and types `iface` as their union (i.e. Circle | Square). If
+// `discriminator` is not empty the members of the union are typed with the
+// property `discriminator` holding their type name (i.e. Circle & { "type":
+// "Circle"; }). The structs are declared as is, so the Go types must marshal
+// the property themselves when they are used through `iface`. If
+// `discriminator` is empty it defaults to the name of the field Type of the
+// implementers, which must be the same for all of them, and the union is not
+// discriminated if none of them have such a field.
+// Implementers that refer back to `iface` are declared, so AddUnion should be
+// called before other types that refer to `iface` are added.
+func (g *Generator) AddUnion(iface reflect.Type, impls []reflect.Type, discriminator string) {
+	g.lock()
+	defer g.unlock()
+
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("tsreflect: type %q is not an interface", iface.String()))
+	}
+
+	// names holds the names of the fields Type of the implementers, which is
+	// empty for implementers without one.
+	names := make(map[string]struct{})
+
+	for _, impl := range impls {
+		st := impl
+		if st.Kind() == reflect.Pointer {
+			st = st.Elem()
+		}
+
+		if st.Kind() != reflect.Struct || !impl.Implements(iface) && !reflect.PointerTo(st).Implements(iface) {
+			panic(fmt.Sprintf("tsreflect: type %q is not a struct that implements %q", impl.String(), iface.String()))
+		}
+
+		name := ""
+		for _, f := range jsonFields(st, g.tagNames) {
+			if f.Name == "Type" {
+				name = f.name
+			}
+		}
+
+		names[name] = struct{}{}
+	}
+
+	if discriminator == "" {
+		if len(names) > 1 {
+			panic(fmt.Sprintf("tsreflect: implementers of %q do not all have a field Type with the same name", iface.String()))
+		}
+
+		for name := range names {
+			discriminator = name
+		}
+	}
+
+	// The union is set before the implementers are added, so that cycles
+	// through it are found.
+	g.unions[iface] = union{
+		impls:         impls,
+		discriminator: discriminator,
+	}
+
+	for _, impl := range impls {
+		g.addRoot(impl)
+	}
+}
+
+// AddStream adds `typ` to the generator together with a declaration `name` of
+// a stream of it, such as newline-delimited JSON where every line is a `typ`
+// (i.e type Events = AsyncIterable<Event>).
+func (g *Generator) AddStream(typ reflect.Type, name string) {
+	g.lock()
+	defer g.unlock()
+
+	g.addRoot(typ)
+
+	g.alias(name, func(s scope) string {
+		return fmt.Sprintf("%s<%s>", g.streamType, g.typeOf(s, typ, false))
+	})
+}
+
+// alias adds a type alias declaration `name` rendered by `alias`.
+func (g *Generator) alias(name string, alias func(s scope) string) {
+	if g.isNameTaken(name) {
+		panic(fmt.Sprintf("tsreflect: name %q is taken", name))
+	}
+
+	g.names[name] = nil
+	g.aliases[name] = alias
+}
+
+// TypeOf returns the TypeScript type for `typ`.
+func (g *Generator) TypeOf(typ reflect.Type) string {
+	g.rlock()
+	defer g.runlock()
+
+	return g.typeOf(g.at, typ, false)
+}
+
+// Declarations returns the required top-level declarations for the TypeScript
+// types in the generator.
+func (g *Generator) Declarations() []Declaration {
+	g.rlock()
+	defer g.runlock()
+
+	return g.declarationList()
+}
+
+// declarationList returns the declarations of the generator with the
+// declaration hook applied.
+func (g *Generator) declarationList() []Declaration {
+	ds := g.unhookedDeclarations()
+
+	if g.declarationHook != nil {
+		for i, d := range ds {
+			ds[i] = g.declarationHook(d)
+		}
+	}
+
+	return ds
+}
+
+// unhookedDeclarations returns the declarations of the generator as they are
+// rendered, before the declaration hook is applied.
+func (g *Generator) unhookedDeclarations() (ds []Declaration) {
+	names := make([]string, 0, len(g.names))
+	for name := range g.names {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var used map[string]struct{}
+	if g.pruneUnused {
+		used = g.used()
+	}
+
+	for _, name := range names {
+		if _, ok := used[name]; g.pruneUnused && !ok {
+			continue
+		}
+
+		if d, ok := g.declaration(scope{path: name}, name); ok {
+			d.Exported = g.exportMode == exportAll || g.exportMode == exportRoots && g.isRoot(name)
+			ds = append(ds, d)
+		}
+	}
+
+	if g.topologicalOrder {
+		ds = g.sortTopological(ds)
+	}
+
+	return
+}
+
+// sortTopological orders the declarations `ds` so that they come after the
+// declarations they refer to, declarations in a cycle are ordered by name.
+func (g *Generator) sortTopological(ds []Declaration) []Declaration {
+	index := make(map[string]int, len(ds))
+	for i, d := range ds {
+		index[d.Name] = i
+	}
+
+	sorted := make([]Declaration, 0, len(ds))
+	visited := make(map[string]bool, len(ds))
+
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+
+		visited[name] = true
+
+		refs := make(map[string]struct{})
+		g.declaration(scope{path: name, refs: refs, quiet: true}, name)
+
+		deps := make([]string, 0, len(refs))
+		for dep := range refs {
+			deps = append(deps, dep)
+		}
+
+		sort.Strings(deps)
+
+		for _, dep := range deps {
+			if _, ok := index[dep]; ok {
+				visit(dep)
+			}
+		}
+
+		sorted = append(sorted, ds[index[name]])
+	}
+
+	for _, d := range ds {
+		visit(d.Name)
+	}
+
+	return sorted
+}
+
+// isRoot reports whether `name` is the declaration of an added type or alias.
+func (g *Generator) isRoot(name string) bool {
+	if _, ok := g.aliases[name]; ok {
+		return true
+	}
+
+	for _, typ := range g.roots {
+		if gen, ok := g.generics[typ]; ok && gen.base == name || g.symbols[typ] == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// declaration renders the declaration of `name`, it reports false if `name`
+// is not declared (i.e. it is unknown, imported, inlined or has a custom type).
+func (g *Generator) declaration(s scope, name string) (Declaration, bool) {
+	if _, ok := g.imports[name]; ok {
+		return Declaration{}, false
+	}
+
+	if alias, ok := g.aliases[name]; ok {
+		return Declaration{
+			Name: name,
+			Type: alias(s),
+			Kind: AliasDeclaration,
+		}, true
+	}
+
+	typ, ok := g.names[name]
+	if !ok || typ == nil {
+		return Declaration{}, false
+	}
+
+	gen, isGeneric := g.generics[typ]
+
+	if typ.Kind() == reflect.Struct && g.isInline(typ) && !isGeneric {
+		return Declaration{}, false
+	}
+
+	if g.hasCustomType(typ) {
+		return Declaration{}, false
+	}
+
+	d := Declaration{
+		Name: name,
+	}
+
+	var sb strings.Builder
+	switch {
+	case isGeneric:
+		d.Params = gen.paramNames()
+		s = g.genericScope(s, gen)
+		g.writeStructDecl(&sb, s, typ)
+		d.Type = sb.String()
+	default:
+		g.declare(&sb, &d, s, typ)
+	}
+
+	if g.typeAliases && d.Kind == InterfaceDeclaration {
+		d.Kind = AliasDeclaration
+	}
+
+	return d, true
+}
+
+// used returns the names of the declarations that are referenced by name,
+// starting from the added types and aliases and following the declarations
+// they refer to.
+func (g *Generator) used() map[string]struct{} {
+	refs := make(map[string]struct{})
+	s := scope{refs: refs, quiet: true}
+
+	for _, typ := range g.roots {
+		g.typeOf(s, typ, false)
+	}
+
+	for name := range g.aliases {
+		refs[name] = struct{}{}
+	}
+
+	seen := make(map[string]struct{})
+	for len(seen) < len(refs) {
+		for name := range refs {
+			if _, ok := seen[name]; ok {
+				continue
+			}
+
+			seen[name] = struct{}{}
+			g.declaration(scope{path: name, refs: refs, quiet: true}, name)
+		}
+	}
+
+	return refs
+}
+
+// declare writes the declaration of the named type `typ` to `d`.
+func (g *Generator) declare(sb *strings.Builder, d *Declaration, s scope, typ reflect.Type) {
+	if members, ok := g.constEnums[typ]; ok {
+		d.Kind = ConstEnumDeclaration
+		d.Type = constEnumBody(members)
+		return
+	}
+
+	if union, ok := g.enums[typ]; ok {
+		d.Kind = AliasDeclaration
+		d.Type = union
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		if g.isEmptyRecord(typ) {
+			d.Kind = AliasDeclaration
+			d.Type = "Record<string, never>"
+			return
+		}
+
+		g.writeStructDecl(sb, s, typ)
+		d.Type = sb.String()
+	case reflect.String:
+		d.Kind = AliasDeclaration
+		d.Type = fmt.Sprintf("string & { readonly __brand: %q }", d.Name)
+	}
+}
+
+// DeclarationsTypeScript returns the required top-level declarations for the
+// TypeScript types in the generator as a TypeScript string.
+func (g *Generator) DeclarationsTypeScript() string {
+	g.rlock()
+	defer g.runlock()
+
+	return g.declarations(false)
+}
+
+// DeclarationsJSDoc returns the required top-level declarations for the
+// TypeScript types in the generator as a JSDoc string.
+func (g *Generator) DeclarationsJSDoc() string {
+	g.rlock()
+	defer g.runlock()
+
+	return g.declarations(true)
+}
+
+// add adds `typ` and the types it refers to, `stack` holds the types that are
+// being added and is used to detect circular types, and `path` is the path to
+// `typ` from the added type passed to the visitor.
+func (g *Generator) add(typ reflect.Type, stack []reflect.Type, path []string) {
+	if typ == nil {
+		return
+	}
+
+	if _, ok := g.types[typ]; ok {
+		g.markCircular(typ, stack)
+		return
+	}
+
+	g.types[typ] = struct{}{}
+
+	if g.typeVisitor != nil {
+		g.typeVisitor(typ, path)
+	}
+
+	stack = append(stack, typ)
+
+	switch typ.Kind() {
+	case reflect.Array, reflect.Slice:
+		g.add(typ.Elem(), stack, subpath(path, "[]"))
+	case reflect.Pointer:
+		g.add(typ.Elem(), stack, path)
+	case reflect.Map:
+		g.add(typ.Key(), stack, subpath(path, "{key}"))
+		g.add(typ.Elem(), stack, subpath(path, "{}"))
+	case reflect.Func:
+		for i := 0; i < typ.NumIn(); i++ {
+			g.add(typ.In(i), stack, subpath(path, "()"))
+		}
+
+		for i := 0; i < typ.NumOut(); i++ {
+			g.add(typ.Out(i), stack, subpath(path, "()"))
+		}
+	case reflect.Struct:
+		if g.brandedTime && typ == typeOfTime && g.timeBrand == "" {
+			g.timeBrand = sequentialNamer("ISODateString", g.isNameTaken)
+			g.alias(g.timeBrand, func(s scope) string {
+				return "string & { __iso: void }"
+			})
+		}
+
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+
+			if isIgnoredField(f, g.tagNames) && !g.isIncludedOmit(f) {
+				continue
+			}
+
+			g.add(f.Type, stack, subpath(path, f.Name))
+		}
+
+		hasName := typ.Name() != ""
+		hasExportedFields := len(jsonFields(typ, g.tagNames)) > 0
+
+		if _, ok := g.generics[typ]; hasName && (hasExportedFields || g.emptyRecords) && !ok {
+			g.register(typ)
+		}
+	case reflect.String:
+		if g.brandedStrings && typ.PkgPath() != "" && !g.hasCustomType(typ) {
+			g.register(typ)
+		}
+	case reflect.Interface:
+		for _, impl := range g.unions[typ].impls {
+			g.add(impl, stack, path)
+		}
+
+		if g.jsonValues && g.valueName == "" && !g.hasCustomType(typ) {
+			g.valueName = sequentialNamer("JSONValue", g.isNameTaken)
+			g.alias(g.valueName, func(s scope) string {
+				return fmt.Sprintf("string | number | boolean | null | %[1]s[] | { [key: string]: %[1]s }", s.ref(g.valueName))
+			})
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if g.brandedUnsigned && g.uintBrand == "" {
+			g.uintBrand = sequentialNamer("Uint", g.isNameTaken)
+			g.alias(g.uintBrand, func(s scope) string {
+				return "number & { __uint: void }"
+			})
+		}
+	}
+}
+
+// subpath returns a copy of `path` with `name` appended.
+func subpath(path []string, name string) []string {
+	return append(path[:len(path):len(path)], name)
+}
+
+// markCircular marks the first named struct in the cycle that `typ` closes on
+// `stack` as circular, so that the cycle is broken by its declaration.
+func (g *Generator) markCircular(typ reflect.Type, stack []reflect.Type) {
+	for i, t := range stack {
+		if t != typ {
+			continue
+		}
+
+		for _, t := range stack[i:] {
+			if t.Kind() == reflect.Struct && t.Name() != "" {
+				g.circular[t] = struct{}{}
+				return
+			}
+		}
+
+		return
+	}
+}
+
+// register names `typ` so that it is declared.
+func (g *Generator) register(typ reflect.Type) {
+	isNameTaken := func(name string) bool {
+		return g.isNameTaken(g.namePrefix + name + g.nameSuffix)
+	}
+
+	if hasInterface(typeOfTypeScriptNamer, typ) && !isNamePromoted(typ) {
+		name := reflect.New(typ).Elem().Interface().(TypeScriptNamer).TypeScriptName()
+
+		if !isIdentifier(name) {
+			panic(fmt.Sprintf("tsreflect: type %q is named %q which is not a valid identifier", typ.String(), name))
+		}
+
+		if g.isNameTaken(name) {
+			panic(fmt.Sprintf("tsreflect: name %q is taken", name))
+		}
+
+		g.symbols[typ] = name
+		g.names[name] = typ
+
+		return
+	}
+
+	name := g.namer(typ, isNameTaken)
+
+	if !isIdentifier(name) {
+		sanitized := sequentialNamer(sanitizeIdentifier(name), isNameTaken)
+		g.warnf(scope{}, "namer returned %q which is not a valid identifier, using %q.", name, sanitized)
+		name = sanitized
+	}
+
+	name = g.namePrefix + name + g.nameSuffix
+
+	if g.isNameTaken(name) {
+		switch g.collisionPolicy {
+		case NamerCollisionSuffix:
+			name = sequentialNamer(name, g.isNameTaken)
+		case NamerCollisionError:
+			g.errs = append(g.errs, fmt.Errorf("tsreflect: namer returned taken name %q for type %q", name, typ.String()))
+			name = sequentialNamer(name, g.isNameTaken)
+		default:
+			panic(fmt.Sprintf("tsreflect: namer returned taken name %q", name))
+		}
+	}
+
+	g.symbols[typ] = name
+	g.names[name] = typ
+}
+
+// hasInterface reports whether values of `typ` implement `u`. Interfaces are
+// marshaled as their dynamic values so they never do, even when their method
+// set includes `u`.
+func hasInterface(u reflect.Type, typ reflect.Type) bool {
+	if typ.Kind() == reflect.Interface {
+		return false
+	}
+
+	if typ.Kind() == reflect.Pointer && typ.Implements(u) {
+		return !typ.Elem().Implements(u)
+	}
+
+	return typ.Implements(u)
+}
+
+// isNamePromoted reports whether the TypeScriptName method of `typ` is
+// promoted from an embedded field instead of declared on `typ`. A struct can
+// declare a method that shadows a promoted one, so the name is promoted if it
+// is the name of an embedded field.
+func isNamePromoted(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+
+	v := reflect.New(typ)
+
+	embeds := false
+
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.Anonymous {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Interface {
+			embeds = embeds || ft.Implements(typeOfTypeScriptNamer)
+			continue
+		}
+
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+
+			// Embedded pointers are allocated, so that methods promoted
+			// through them can be called.
+			if field := v.Elem().Field(i); field.CanSet() {
+				field.Set(reflect.New(ft))
+			}
+		}
+
+		if name, ok := typeScriptName(reflect.New(ft)); ok {
+			embeds = true
+			names = append(names, name)
+		}
+	}
+
+	if !embeds {
+		return false
+	}
+
+	// The method panics if it is promoted through a nil interface or pointer
+	// that could not be allocated.
+	name, ok := typeScriptName(v)
+	if !ok {
+		return true
+	}
+
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// typeScriptName returns the name given by the TypeScriptName method of `v`,
+// it reports false if `v` has no such method or the method panics.
+func typeScriptName(v reflect.Value) (name string, ok bool) {
+	namer, ok := v.Interface().(TypeScriptNamer)
+	if !ok {
+		return "", false
+	}
+
+	defer func() {
+		if recover() != nil {
+			name, ok = "", false
+		}
+	}()
+
+	return namer.TypeScriptName(), true
+}
+
+// A scope is the position of a type in the type graph being rendered, used to
+// give warnings context (i.e. Users[].Avatar).
+type scope struct {
+	path  string
+	depth int
+
+	// params maps the type arguments of a generic declaration to the names
+	// of its type parameters.
+	params map[reflect.Type]string
+
+	// concrete holds the properties of a generic declaration that are typed
+	// without params, it is not passed on to nested fields.
+	concrete map[string]bool
+
+	// refs collects the names of the declarations that are referenced.
+	refs map[string]struct{}
+
+	// decl is the type added with AddNamed that is being declared.
+	decl reflect.Type
+
+	// quiet suppresses warnings for types that are rendered more than once.
+	quiet bool
+
+	// rewriting is set for types rendered by the type rewriter, which are not
+	// rewritten.
+	rewriting bool
+
+	// mapKey is set for the keys of maps, which encoding/json marshals as
+	// strings.
+	mapKey bool
+}
+
+// ref records that the declaration `name` is referenced and returns it.
+func (s scope) ref(name string) string {
+	if s.refs != nil {
+		s.refs[name] = struct{}{}
+	}
+
+	return name
+}
+
+func (s scope) field(name string) scope {
+	s.concrete = nil
+
+	if s.path == "" {
+		s.path = name
+	} else {
+		s.path += "." + name
+	}
+
+	s.depth++
+
+	return s
+}
+
+func (s scope) key() scope {
+	s.mapKey = true
+
+	return s
+}
+
+func (s scope) elem() scope {
+	s.path += "[]"
+	s.depth++
+
+	return s
+}
+
+func (s scope) value() scope {
+	s.path += "{}"
+	s.depth++
+
+	return s
+}
+
+// seenWarnings holds the warnings that have been given, so that types rendered
+// more than once are only warned about once. Warnings are given while the
+// generator is read, so they have a lock of their own.
+type seenWarnings struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// add records the warning `message` at `path`, it reports false if it has
+// been given already.
+func (w *seenWarnings) add(path, message string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := path + "\x00" + message
+	if _, ok := w.seen[key]; ok {
+		return false
+	}
+
+	w.seen[key] = struct{}{}
+
+	return true
+}
+
+func (g *Generator) warnf(s scope, format string, a ...any) {
+	if !g.warnings || s.quiet || !g.warned.add(s.path, fmt.Sprintf(format, a...)) {
+		return
+	}
+
+	if s.path == "" {
+		g.warn("tsreflect: WARNING "+format, a...)
+		return
+	}
+
+	g.warn("tsreflect: WARNING field %s: "+format, append([]any{s.path}, a...)...)
+}
+
+func (g *Generator) typeOf(s scope, typ reflect.Type, optional bool) string {
+	ts := g.render(s, typ, optional)
+
+	if g.typeRewriter == nil || s.rewriting {
+		return ts
+	}
+
+	s.rewriting = true
+
+	return g.typeRewriter(g.view(s), typ, ts)
+}
+
+func (g *Generator) render(s scope, typ reflect.Type, optional bool) string {
+	if typ == nil {
+		return "any"
+	}
+
+	if param, ok := s.params[typ]; ok {
+		return param
+	}
+
+	if name, ok := g.named[typ]; ok && typ != s.decl {
+		// Like other named types the declaration is typed without null,
+		// which is added where the type is used.
+		inner := scope{path: s.path, depth: s.depth, params: s.params, decl: typ, quiet: true}
+
+		if !optional && isNullable(g.typeOf(inner, typ, false)) {
+			return s.ref(name) + " | null"
+		}
+
+		return s.ref(name)
+	}
+
+	if union, ok := g.enums[typ]; ok {
+		if name, ok := g.symbols[typ]; ok {
+			return s.ref(name)
+		}
+
+		return union
+	}
+
+	if u, ok := g.unions[typ]; ok {
+		members := make([]string, len(u.impls))
+		for i, impl := range u.impls {
+			members[i] = g.typeOf(s, impl, true)
+
+			if u.discriminator != "" {
+				name := impl.Name()
+				if impl.Kind() == reflect.Pointer {
+					name = impl.Elem().Name()
+				}
+
+				tag := g.objectType([]Field{{Name: u.discriminator, Type: strconv.Quote(name)}})
+				members[i] = fmt.Sprintf("%s & %s", group(members[i]), tag)
+			}
+		}
+
+		return strings.Join(members, " | ")
+	}
+
+	if g.maxDepth > 0 && s.depth > g.maxDepth {
+		g.warnf(s, "maximum depth of %d exceeded by type %q.", g.maxDepth, typ.String())
+		return "any"
+	}
+
+	if hasInterface(typeOfTypeScriptTyper, typ) {
+		t := reflect.New(typ).Elem().Interface().(TypeScriptTyper)
+		return t.TypeScriptType(g.view(s), optional)
+	}
+
+	if typer, ok := g.typers[typ]; ok {
+		return typer(g.view(s), typ, optional)
+	}
+
+	if typer, ok := g.kindTypers[typ.Kind()]; ok {
+		return typer(g.view(s), typ, optional)
+	}
+
+	if isHeader(typ) {
+		if optional || g.nonNullCollections || g.compactNull {
+			return "{ [key: string]: string[] }"
+		}
+
+		return "{ [key: string]: string[] } | null"
+	}
+
+	if hasInterface(typeOfMarshaler, typ) {
+		g.warnf(s, "json.Marshaler implemented for type %q but no corresponding typer could be found.", typ.String())
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if g.precisionWarnings && typ.Bits() == 64 && !s.mapKey {
+			g.warnf(s, "type %q loses precision as a number beyond 2^53, use the \"string\" tag option or a typer for it.", typ.String())
+		}
+
+		if g.uintBrand != "" && isUnsigned(typ) {
+			return s.ref(g.uintBrand)
+		}
+
+		return "number"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		if name, ok := g.symbols[typ]; ok {
+			return s.ref(name)
+		}
+
+		return "string"
+	case reflect.Array:
+		elem := g.typeOf(s.elem(), typ.Elem(), false)
+
+		s := make([]string, typ.Len())
+		for i := range s {
+			s[i] = elem
+		}
+
+		if g.readonlyTuples {
+			return fmt.Sprintf("readonly [%s]", strings.Join(s, ", "))
+		}
+
+		return fmt.Sprintf("[%s]", strings.Join(s, ", "))
+	case reflect.Slice:
+		elem := group(g.typeOf(s.elem(), typ.Elem(), false))
+
+		if g.readonlySlices {
+			elem = "readonly " + elem
+		}
+
+		if optional || g.nonNullCollections || g.compactNull {
+			return fmt.Sprintf("%s[]", elem)
+		}
+
+		return fmt.Sprintf("%s[] | null", elem)
+	case reflect.Map:
+		key, elem := g.typeOf(s.key(), typ.Key(), false), g.typeOf(s.value(), typ.Elem(), false)
+
+		if optional || g.nonNullCollections || g.compactNull {
+			return fmt.Sprintf("{ [key in (%s)]: (%s) }", key, elem)
+		}
+
+		return fmt.Sprintf("{ [key in (%s)]: (%s) } | null", key, elem)
+	case reflect.Pointer:
+		elem := g.typeOf(s, typ.Elem(), false)
+
+		// any already includes null.
+		if optional || g.compactNull || elem == "any" || isNullable(elem) || g.isJSONValue(typ.Elem()) {
+			return elem
+		}
+
+		return fmt.Sprintf("%s | null", elem)
+	case reflect.Struct:
+		if isSyncType(typ) {
+			g.warnf(s, "type %q is used for synchronization and is marshaled as an empty object.", typ.String())
+		}
+
+		if gen, ok := g.generics[typ]; ok {
+			args := make([]string, len(gen.params))
+			for i, param := range gen.params {
+				args[i] = g.typeOf(s, param, false)
+			}
+
+			return fmt.Sprintf("%s<%s>", s.ref(gen.base), strings.Join(args, ", "))
+		}
+
+		name := g.symbols[typ]
+
+		if name == "" || g.isInline(typ) {
+			if g.isEmptyRecord(typ) {
+				return "Record<string, never>"
+			}
+
+			var sb strings.Builder
+			g.writeStructDecl(&sb, s, typ)
+			return sb.String()
+		}
+
+		return s.ref(name)
+	case reflect.Interface:
+		if g.valueName != "" {
+			return s.ref(g.valueName)
+		}
+
+		return "any"
+	case reflect.Func:
+		return g.funcType(s, typ)
+	default:
+		g.warnf(s, "type %q of kind %s can not be marshaled, using never.", typ.String(), typ.Kind())
+		return "never"
+	}
+}
+
+// funcType returns an arrow function type for the func type `typ`. Error
+// results are left out, a single result is returned as is and multiple results
+// are returned as a tuple. With WithErrorAsResult the result and error are
+// returned in a result object instead.
+func (g *Generator) funcType(s scope, typ reflect.Type) string {
+	// Parameters from `trailing` on are pointers that can be left out.
+	trailing := typ.NumIn()
+	if typ.IsVariadic() {
+		trailing--
+	}
+
+	for g.optionalPointerParams && trailing > 0 && typ.In(trailing-1).Kind() == reflect.Pointer {
+		trailing--
+	}
+
+	params := make([]string, typ.NumIn())
+	for i := range params {
+		name := fmt.Sprintf("arg%d", i)
+		in := typ.In(i)
+
+		switch {
+		case typ.IsVariadic() && i == len(params)-1:
+			params[i] = fmt.Sprintf("...%s: %s", name, g.typeOf(s.field(name), in, true))
+		case g.optionalPointerParams && i >= trailing:
+			params[i] = fmt.Sprintf("%s?: %s", name, g.typeOf(s.field(name), in, true))
+		default:
+			if g.optionalPointerParams && in.Kind() == reflect.Pointer {
+				g.warnf(s.field(name), "pointer parameter can not be optional since it is followed by a required parameter.")
+			}
+
+			params[i] = fmt.Sprintf("%s: %s", name, g.typeOf(s.field(name), in, false))
+		}
+	}
+
+	var results []string
+	var hasError bool
+	for i := 0; i < typ.NumOut(); i++ {
+		if out := typ.Out(i); out != typeOfError {
+			results = append(results, g.typeOf(s.elem(), out, false))
+		} else {
+			hasError = true
+		}
+	}
+
+	result := "void"
+	switch len(results) {
+	case 0:
+	case 1:
+		result = results[0]
+	default:
+		result = fmt.Sprintf("[%s]", strings.Join(results, ", "))
+	}
+
+	if hasError && g.errorResults {
+		if len(results) == 0 {
+			result = `{ "error": string | null; }`
+		} else {
+			result = fmt.Sprintf(`{ "data": %s | null; "error": string | null; }`, result)
+		}
+	}
+
+	return fmt.Sprintf("((%s) => %s)", strings.Join(params, ", "), result)
+}
+
+func (g *Generator) declarations(jsDoc bool) string {
+	return g.frame(g.declarationList(), jsDoc, typeScriptDecl)
+}
+
+// frame writes the declarations `decls` together with the schema hash header,
+// the type only exports and the `declare global {}` wrapper of the generator.
+// TypeScript declarations are written by `declare`.
+func (g *Generator) frame(decls []Declaration, jsDoc bool, declare func(Declaration) string) string {
+	var sb strings.Builder
+
+	if g.schemaHash {
+		sb.WriteString(fmt.Sprintf("// schema: %s\n", g.hash(decls)))
+	}
+
+	if !g.declareGlobal || jsDoc {
+		g.writeDecls(&sb, decls, jsDoc, declare)
+		return sb.String()
+	}
+
+	var inner strings.Builder
+	g.writeDecls(&inner, decls, false, declare)
+
+	sb.WriteString("declare global {\n")
+
+	for _, line := range strings.Split(inner.String(), "\n") {
+		sb.WriteString("  " + line + "\n")
+	}
+
+	sb.WriteString("}\nexport {}")
+
+	return sb.String()
+}
+
+func (g *Generator) writeDecls(sb *strings.Builder, decls []Declaration, jsDoc bool, declare func(Declaration) string) {
+	var exports []string
+
+	if g.typeOnlyExports && !jsDoc {
+		decls = append([]Declaration(nil), decls...)
+
+		for i, decl := range decls {
+			if decl.Exported && decl.Kind != ConstEnumDeclaration {
+				exports = append(exports, decl.Name)
+				decls[i].Exported = false
+			}
+		}
+	}
+
+	for i, decl := range decls {
 		if jsDoc {
-			sb.WriteString("/** @typedef {")
+			g.writeJSDocDecl(sb, decl)
 		} else {
-			sb.WriteString(fmt.Sprintf("interface %s ", decl.Name))
+			g.writeTypeScriptDecl(sb, decl, declare)
+		}
+
+		if i < len(decls)-1 {
+			sb.WriteString("\n")
 		}
+	}
+
+	if len(exports) > 0 {
+		sb.WriteString(fmt.Sprintf("\nexport type { %s }", strings.Join(exports, ", ")))
+	}
+}
+
+// A manifestEntry is a declaration in the manifest returned by Manifest.
+type manifestEntry struct {
+	Name         string   `json:"name"`
+	Kind         string   `json:"kind"`
+	Function     bool     `json:"function"`
+	Package      string   `json:"package,omitempty"`
+	Type         string   `json:"type,omitempty"`
+	Dependencies []string `json:"dependencies"`
+}
 
-		sb.WriteString(decl.Type)
+// Manifest returns a JSON array describing the declarations of the generator,
+// with the kind of each declaration, whether it is a func type, the package
+// path and name of its Go type and the names of the declarations it refers to.
+func (g *Generator) Manifest() []byte {
+	g.rlock()
+	defer g.runlock()
 
-		if jsDoc {
-			sb.WriteString(fmt.Sprintf("} %s */", decl.Name))
+	entries := []manifestEntry{}
+
+	// Dependencies are collected by the names the declarations are rendered
+	// with, and renamed to the names given to them by the declaration hook.
+	decls := g.unhookedDeclarations()
+	hooked := make(map[string]Declaration, len(decls))
+
+	for _, d := range decls {
+		hooked[d.Name] = d
+
+		if g.declarationHook != nil {
+			hooked[d.Name] = g.declarationHook(d)
 		}
+	}
 
-		if i < len(decls)-1 {
-			sb.WriteString("\n")
+	for _, d := range decls {
+		refs := make(map[string]struct{})
+		g.declaration(scope{path: d.Name, refs: refs, quiet: true}, d.Name)
+
+		entry := manifestEntry{
+			Name:         hooked[d.Name].Name,
+			Kind:         hooked[d.Name].Kind.String(),
+			Dependencies: []string{},
+		}
+
+		for dep := range refs {
+			if h, ok := hooked[dep]; ok {
+				dep = h.Name
+			}
+
+			if dep != entry.Name {
+				entry.Dependencies = append(entry.Dependencies, dep)
+			}
+		}
+
+		sort.Strings(entry.Dependencies)
+
+		typ := g.names[d.Name]
+		for t, name := range g.named {
+			if name == d.Name {
+				typ = t
+			}
 		}
+
+		if typ != nil {
+			entry.Function = typ.Kind() == reflect.Func
+			entry.Package = typ.PkgPath()
+			entry.Type, _, _ = strings.Cut(typ.Name(), "[")
+		}
+
+		entries = append(entries, entry)
 	}
 
-	return sb.String()
+	// The entries only hold strings and bools, so marshaling can not fail.
+	bs, _ := json.Marshal(entries)
+
+	return bs
+}
+
+// SchemaHash returns a hash of the TypeScript declarations of the generator,
+// which is stable across runs and changes only when the declarations change.
+func (g *Generator) SchemaHash() string {
+	g.rlock()
+	defer g.runlock()
+
+	return g.hash(g.declarationList())
+}
+
+func (g *Generator) hash(decls []Declaration) string {
+	var sb strings.Builder
+	g.writeDecls(&sb, decls, false, typeScriptDecl)
+
+	sum := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func (g *Generator) writeTypeScriptDecl(sb *strings.Builder, decl Declaration, declare func(Declaration) string) {
+	if source := g.source(decl); source != "" {
+		sb.WriteString(fmt.Sprintf("/** @see %s */\n", source))
+	}
+
+	sb.WriteString(declare(decl))
+}
+
+func typeScriptDecl(decl Declaration) string {
+	name := decl.Name
+	if len(decl.Params) > 0 {
+		name = fmt.Sprintf("%s<%s>", decl.Name, strings.Join(decl.Params, ", "))
+	}
+
+	export := ""
+	if decl.Exported {
+		export = "export "
+	}
+
+	switch decl.Kind {
+	case AliasDeclaration:
+		return fmt.Sprintf("%stype %s = %s", export, name, decl.Type)
+	case ConstEnumDeclaration:
+		return fmt.Sprintf("%sconst enum %s %s", export, name, decl.Type)
+	default:
+		return fmt.Sprintf("%sinterface %s %s", export, name, decl.Type)
+	}
+}
+
+func (g *Generator) writeJSDocDecl(sb *strings.Builder, decl Declaration) {
+	var tags []string
+
+	if len(decl.Params) > 0 {
+		tags = append(tags, fmt.Sprintf("@template %s", strings.Join(decl.Params, ", ")))
+	}
+
+	if props, ok := g.jsDocProperties(decl); ok {
+		tags = append(tags, fmt.Sprintf("@typedef {Object} %s", decl.Name))
+
+		for _, prop := range props {
+			tags = append(tags, fmt.Sprintf("@property %s", prop))
+		}
+	} else if decl.Kind == ConstEnumDeclaration {
+		tags = append(tags, fmt.Sprintf("@typedef {%s} %s", g.enums[g.names[decl.Name]], decl.Name))
+	} else {
+		tags = append(tags, fmt.Sprintf("@typedef {%s} %s", decl.Type, decl.Name))
+	}
+
+	if source := g.source(decl); source != "" {
+		tags = append(tags, fmt.Sprintf("@see %s", source))
+	}
+
+	if len(tags) == 1 {
+		sb.WriteString(fmt.Sprintf("/** %s */", tags[0]))
+		return
+	}
+
+	sb.WriteString("/**\n")
+
+	for _, tag := range tags {
+		sb.WriteString(fmt.Sprintf(" * %s\n", tag))
+	}
+
+	sb.WriteString(" */")
 }
 
-func (g *Generator) writeStructDecl(sb *strings.Builder, typ reflect.Type) {
+// source returns the package qualified name of the Go type declared by `decl`
+// when WithSourceReference is set (i.e. github.com/me/pkg.User).
+func (g *Generator) source(decl Declaration) string {
+	typ := g.names[decl.Name]
+	if !g.sourceReferences || typ == nil || typ.PkgPath() == "" {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(typ.Name(), "[")
+
+	return fmt.Sprintf("%s.%s", typ.PkgPath(), name)
+}
+
+// jsDocProperties returns the `@property` tags of the struct interface
+// declaration `decl`, with optional properties in brackets (i.e. {number} [b]).
+// It reports false if `decl` is not a struct interface, has properties that
+// are not identifiers or was changed by the declaration hook.
+func (g *Generator) jsDocProperties(decl Declaration) ([]string, bool) {
+	typ := g.names[decl.Name]
+	if typ == nil || typ.Kind() != reflect.Struct || decl.Kind != InterfaceDeclaration || g.isHooked(decl) {
+		return nil, false
+	}
+
+	s := scope{path: decl.Name, quiet: true}
+	if gen, ok := g.generics[typ]; ok {
+		s = g.genericScope(s, gen)
+	}
+
+	fields := g.structFields(typ)
+
+	props := make([]string, len(fields))
+	for i, f := range fields {
+		field := g.field(s, f)
+		name := field.Name
+
+		if !isIdentifier(name) {
+			return nil, false
+		}
+
+		if field.Optional {
+			name = fmt.Sprintf("[%s]", name)
+		}
+
+		props[i] = fmt.Sprintf("{%s} %s", jsDocType(fieldType(field)), name)
+	}
+
+	return props, true
+}
+
+// isHooked reports whether the type of `decl` was changed by the declaration
+// hook, so that it can not be rendered again from the Go type.
+func (g *Generator) isHooked(decl Declaration) bool {
+	if g.declarationHook == nil {
+		return false
+	}
+
+	d, ok := g.declaration(scope{path: decl.Name, quiet: true}, decl.Name)
+
+	return !ok || d.Type != decl.Type
+}
+
+// jsDocType returns the TypeScript type `ts` with nullable names written in
+// the JSDoc form (i.e. ?User instead of User | null).
+func jsDocType(ts string) string {
+	if inner, ok := strings.CutSuffix(ts, " | null"); ok && isIdentifier(inner) {
+		return "?" + inner
+	}
+
+	return ts
+}
+
+func (g *Generator) writeStructDecl(sb *strings.Builder, s scope, typ reflect.Type) {
 	sb.WriteString("{ ")
 
-	g.writeStructFields(sb, typ)
+	g.writeStructFields(sb, s, typ)
 
 	sb.WriteString("}")
 }
 
-func (g *Generator) writeStructFields(sb *strings.Builder, typ reflect.Type) {
-	for i := 0; i < typ.NumField(); i++ {
-		f := typ.Field(i)
+func (g *Generator) writeStructFields(sb *strings.Builder, s scope, typ reflect.Type) {
+	fields := g.structFields(typ)
 
-		if !f.IsExported() || hasTagOmit(f) {
+	props := make([]Field, len(fields))
+	for i, f := range fields {
+		props[i] = g.field(s, f)
+
+		if f.omitted {
 			continue
 		}
 
-		if f.Anonymous {
-			g.writeStructFields(sb, f.Type)
-		} else {
-			sb.WriteString(g.structField(f))
-			sb.WriteString("; ")
+		for _, other := range fields[:i] {
+			if strings.EqualFold(f.name, other.name) {
+				g.warnf(s, "properties %q and %q of type %q differ only in case, encoding/json unmarshals them case-insensitively.", other.name, f.name, typ.String())
+			}
 		}
 	}
-}
 
-func hasTagOmit(f reflect.StructField) bool {
-	if tag, ok := f.Tag.Lookup("json"); ok && tag == "-" {
-		return true
+	if g.dottedNesting {
+		var clashes []string
+		props, clashes = nestFields(props, g.objectType)
+
+		for _, name := range clashes {
+			g.warnf(s, "property %q of type %q clashes with the dotted properties nested in it, they are not nested.", name, typ.String())
+		}
 	}
 
-	return false
+	writeFields(sb, props, g.fieldTerminator)
 }
 
-func (g *Generator) structField(f reflect.StructField) string {
-	name := f.Name
-	omit := false
+func (g *Generator) objectType(fields []Field) string {
+	return objectType(fields, g.fieldTerminator)
+}
+
+// writeFields writes the properties `fields` of an object type, each followed
+// by `terminator`.
+func writeFields(sb *strings.Builder, fields []Field, terminator string) {
+	for _, f := range fields {
+		if f.Readonly {
+			sb.WriteString("readonly ")
+		}
 
-	var typ string
-	if tag, ok := f.Tag.Lookup("json"); ok {
-		if !strings.ContainsRune(tag, ',') {
-			name = tag
+		if f.Optional {
+			sb.WriteString(fmt.Sprintf("%q?: %s", f.Name, fieldType(f)))
 		} else {
-			parts := strings.Split(tag, ",")
+			sb.WriteString(fmt.Sprintf("%q: %s", f.Name, fieldType(f)))
+		}
+
+		sb.WriteString(terminator)
+		sb.WriteString(" ")
+	}
+}
+
+func objectType(fields []Field, terminator string) string {
+	var sb strings.Builder
+
+	sb.WriteString("{ ")
+	writeFields(&sb, fields, terminator)
+	sb.WriteString("}")
+
+	return sb.String()
+}
+
+// nestFields nests the fields with dotted names in objects of the fields that
+// share the part before the first dot (i.e. "a.b" and "a.c" in "a": { "b",
+// "c" }), which are rendered by `object`. A nested object is optional if all
+// of its fields are. Fields whose part before the first dot is the name of
+// another field are not nested, since the object would clash with it, and the
+// names of the fields they clash with are returned as `clashes`.
+func nestFields(fields []Field, object func([]Field) string) (out []Field, clashes []string) {
+	plain := make(map[string]bool)
+	for _, f := range fields {
+		if !strings.Contains(f.Name, ".") {
+			plain[f.Name] = true
+		}
+	}
+
+	nested := make(map[string]bool)
+
+	for i, f := range fields {
+		head, _, ok := strings.Cut(f.Name, ".")
+		if !ok {
+			out = append(out, f)
+			continue
+		}
 
-			if parts[0] != "" {
-				name = parts[0]
+		if plain[head] {
+			if !nested[head] {
+				clashes = append(clashes, head)
 			}
-			switch parts[1] {
-			case "string":
-				typ = "string"
-			case "omitempty":
-				omit = true
+
+			nested[head] = true
+			out = append(out, f)
+
+			continue
+		}
+
+		if nested[head] {
+			continue
+		}
+
+		nested[head] = true
+
+		var children []Field
+		optional, readonly := true, true
+
+		for _, other := range fields[i:] {
+			if prefix, rest, ok := strings.Cut(other.Name, "."); ok && prefix == head {
+				other.Name = rest
+				children = append(children, other)
+				optional = optional && other.Optional
+				readonly = readonly && other.Readonly
 			}
 		}
-	}
 
-	if typ == "" {
-		typ = g.typeOf(f.Type, omit)
+		children, inner := nestFields(children, object)
+		for _, name := range inner {
+			clashes = append(clashes, head+"."+name)
+		}
+
+		out = append(out, Field{
+			Name:     head,
+			Type:     object(children),
+			Optional: optional,
+			Readonly: readonly,
+		})
 	}
 
-	if omit {
-		return fmt.Sprintf("%q?: %s", name, typ)
+	return
+}
+
+// structFields returns the fields of the struct `typ` that are typed, which
+// are the fields encoding/json marshals and the fields included with
+// WithIncludeOmitted.
+func (g *Generator) structFields(typ reflect.Type) []jsonField {
+	fields := jsonFields(typ, g.tagNames)
+
+	if g.includeOmitted {
+		fields = g.withOmittedFields(typ, fields)
 	}
 
-	return fmt.Sprintf("%q: %s", name, typ)
+	return fields
 }
 
-func countExportedFields(typ reflect.Type) int {
-	if typ.Kind() != reflect.Struct {
-		return 0
+// withOmittedFields adds the fields of `typ` included with WithIncludeOmitted to
+// `fields` in struct order, unless their name is used by a marshaled field.
+func (g *Generator) withOmittedFields(typ reflect.Type, fields []jsonField) []jsonField {
+	names := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		names[f.name] = true
 	}
 
-	var count int
 	for i := 0; i < typ.NumField(); i++ {
-		f := typ.Field(i)
+		sf := typ.Field(i)
 
-		if !f.IsExported() || hasTagOmit(f) {
+		if !g.isIncludedOmit(sf) || names[sf.Name] {
 			continue
 		}
 
-		if f.Anonymous {
-			count += countExportedFields(f.Type)
-		} else {
-			count += 1
-		}
+		fields = append(fields, jsonField{
+			StructField: sf,
+			name:        sf.Name,
+			index:       []int{i},
+			omitted:     true,
+			owner:       typ,
+		})
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return lessIndex(fields[i].index, fields[j].index)
+	})
+
+	return fields
+}
+
+// isReadonly reports whether the field `f` is readonly with WithReadonly or
+// the `readonly` option of the `ts` struct tag.
+func (g *Generator) isReadonly(f jsonField) bool {
+	return g.readonlyProps || parseTSTag(f.StructField).readonly
+}
+
+// isIncludedOmit reports whether the field `f` tagged `json:"-"` is included
+// with WithIncludeOmitted.
+func (g *Generator) isIncludedOmit(f reflect.StructField) bool {
+	return g.includeOmitted && f.IsExported() && hasTagOmit(f, g.tagNames)
+}
+
+// A property is how a struct field is marshaled.
+type property struct {
+	name     string
+	optional bool
+
+	// quoted is set for fields marshaled as strings by the `string` tag
+	// option, which can be null if nullable is set.
+	quoted   bool
+	nullable bool
+
+	// omitNull is set if the type of the field is rendered without null for
+	// nil values, since they are left out.
+	omitNull bool
+
+	// literal is the type of fields registered with RegisterConstField.
+	literal string
+
+	// override is the type of fields set with the `type=` option of the `ts`
+	// struct tag.
+	override string
+
+	// undefined is set for optional fields that are typed as undefined as
+	// well with WithDefensiveOptionals.
+	undefined bool
+}
+
+// property resolves the property name and optionality of the struct field `f`.
+func (g *Generator) property(f jsonField) (p property) {
+	p.name = f.name
+
+	// encoding/json omits empty slices and maps as well as nil ones, so
+	// optional collections are never null.
+	p.optional = f.tag.omitempty && isOmittable(f.Type)
+
+	if _, ok := f.Tag.Lookup("default"); ok && g.defaultTagOptional {
+		p.optional = true
+	}
+
+	isPointer := f.Type.Kind() == reflect.Pointer
+	keepNull := isPointer && (g.omitemptyNullable || g.maximalNullability || g.defensiveOptionals)
+
+	if isPointer && g.maximalNullability {
+		p.optional = true
+	}
+
+	p.literal = g.consts[constField{f.owner, f.Name}]
+	p.override = parseTSTag(f.StructField).typ
+	p.quoted = f.tag.string && isQuotable(f.Type)
+	p.nullable = isPointer && (!p.optional || keepNull)
+	p.omitNull = p.optional && !keepNull
+	p.undefined = g.defensiveOptionals && isPointer && p.optional && p.literal == "" && p.override == ""
+
+	if g.compactNull && isNilable(f.Type) {
+		p.optional = true
+		p.nullable = false
+		p.omitNull = true
+	}
+
+	// Fields that are optional only because of WithAllFieldsOptional are still
+	// marshaled as before, so their types are left unchanged.
+	if g.allOptional {
+		p.optional = true
+	}
+
+	return
+}
+
+// field resolves the property of the struct field `f`.
+func (g *Generator) field(s scope, f jsonField) Field {
+	p := g.property(f)
+
+	if s.concrete[p.name] {
+		s.params = nil
+	}
+
+	field := Field{
+		Name:      p.name,
+		Optional:  p.optional || f.promoted || f.omitted,
+		Readonly:  f.omitted || g.isReadonly(f),
+		Undefined: p.undefined,
+	}
+
+	switch {
+	case p.override != "":
+		field.Type = p.override
+	case p.literal != "":
+		field.Type = p.literal
+	case p.quoted && p.nullable:
+		field.Type = "string | null"
+	case p.quoted:
+		field.Type = "string"
+	default:
+		field.Type = g.typeOf(s.field(p.name), f.Type, p.omitNull)
+	}
+
+	return field
+}
+
+// fieldType returns the TypeScript type of the property `f`, with undefined
+// if it can be undefined.
+func fieldType(f Field) string {
+	if f.Undefined {
+		return f.Type + " | undefined"
+	}
+
+	return f.Type
+}
+
+func (g *Generator) isInline(typ reflect.Type) bool {
+	if _, ok := g.circular[typ]; ok {
+		return false
+	}
+
+	if _, ok := g.imports[g.symbols[typ]]; ok {
+		return false
+	}
+
+	if inline, ok := g.inline[typ]; ok {
+		return inline
 	}
 
-	return count
+	return g.flatten
+}
+
+// isEmptyRecord reports whether the struct `typ` has no fields and is typed as
+// an empty record.
+func (g *Generator) isEmptyRecord(typ reflect.Type) bool {
+	return g.emptyRecords && len(jsonFields(typ, g.tagNames)) == 0
 }
 
 func (g *Generator) hasCustomType(typ reflect.Type) bool {
 	_, ok := g.typers[typ]
+	_, isKind := g.kindTypers[typ.Kind()]
+
+	return ok || isKind || isHeader(typ) || hasInterface(typeOfTypeScriptTyper, typ)
+}
+
+// syncTypes are the names of the types of sync and sync/atomic, which have no
+// exported fields and are marshaled as empty objects.
+var syncTypes = map[string][]string{
+	"sync":        {"Cond", "Map", "Mutex", "Once", "Pool", "RWMutex", "WaitGroup"},
+	"sync/atomic": {"Bool", "Int32", "Int64", "Pointer", "Uint32", "Uint64", "Uintptr", "Value"},
+}
+
+// isSyncType reports whether `typ` is a type of sync or sync/atomic, they are
+// matched by name like http.Header.
+func isSyncType(typ reflect.Type) bool {
+	name, _, _ := strings.Cut(typ.Name(), "[")
+
+	for _, n := range syncTypes[typ.PkgPath()] {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
 
-	return ok || hasInterface(typeOfTypeScriptTyper, typ)
+// isHeader reports whether `typ` is http.Header, it is matched by name so that
+// net/http is not imported.
+func isHeader(typ reflect.Type) bool {
+	return typ.PkgPath() == "net/http" && typ.Name() == "Header"
+}
+
+// isJSONValue reports whether `typ` is typed as the JSONValue declaration.
+func (g *Generator) isJSONValue(typ reflect.Type) bool {
+	return g.valueName != "" && typ.Kind() == reflect.Interface && !g.hasCustomType(typ)
 }
 
 func (g *Generator) isNameTaken(name string) bool {
@@ -479,6 +2883,102 @@ func (g *Generator) isNameTaken(name string) bool {
 	return ok
 }
 
+// group parenthesizes the type `ts` if it can not be used as the element of
+// an array type as is, that is if it is a union, an intersection, a function
+// or a readonly type (i.e. (number | null)[]).
+func group(ts string) string {
+	if strings.HasPrefix(ts, "readonly ") {
+		return fmt.Sprintf("(%s)", ts)
+	}
+
+	depth := 0
+	quoted := false
+
+	for i := 0; i < len(ts); i++ {
+		c := ts[i]
+
+		switch {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(' || c == '[' || c == '{' || c == '<':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == '>' && i > 0 && ts[i-1] == '=':
+			if depth == 0 {
+				return fmt.Sprintf("(%s)", ts)
+			}
+		case c == '>':
+			depth--
+		case (c == '|' || c == '&') && depth == 0:
+			return fmt.Sprintf("(%s)", ts)
+		}
+	}
+
+	return ts
+}
+
+// isNilable reports whether a value of `typ` can be nil and marshaled as null.
+func isNilable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		return true
+	default:
+		return false
+	}
+}
+
+// isUnsigned reports whether `typ` is an unsigned integer.
+func isUnsigned(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+// isNullable reports whether the type `ts` is a union with null.
+func isNullable(ts string) bool {
+	return strings.HasSuffix(ts, " | null") && group(ts) != ts
+}
+
+var (
+	reQualifier  = regexp.MustCompile(`[\w./-]+\.|·\d+`)
+	reIdentifier = regexp.MustCompile(`[^\p{L}\p{N}_$]+`)
+	reSeparator  = regexp.MustCompile(`([._-]|\s)+`)
+)
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && r != '$' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// sanitizeIdentifier turns `s` into a valid identifier, package qualifiers
+// are dropped from type names (i.e Page[main.User] becomes PageUser).
+func sanitizeIdentifier(s string) string {
+	parts := reIdentifier.Split(reQualifier.ReplaceAllString(s, ""), -1)
+	for i, part := range parts {
+		parts[i] = title(part)
+	}
+
+	s = strings.Join(parts, "")
+
+	if s == "" || unicode.IsDigit([]rune(s)[0]) {
+		return "_" + s
+	}
+
+	return s
+}
+
 func title(s string) string {
 	if s == "" {
 		return ""
@@ -490,9 +2990,7 @@ func title(s string) string {
 }
 
 func pascalCase(s string) string {
-	re := regexp.MustCompile(`([._-]|\s)+`)
-
-	parts := re.Split(s, -1)
+	parts := reSeparator.Split(s, -1)
 	for i, part := range parts {
 		parts[i] = title(part)
 	}
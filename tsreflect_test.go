@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"net/http"
 	"os"
-	"os/exec"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 	"unsafe"
@@ -60,20 +62,8 @@ func programOfValue[T any](v T, os ...Option) (string, error) {
 }
 
 func typecheckSource(source string) error {
-	file := fmt.Sprintf("typecheck-%d.ts", rand.Int())
-
-	err := os.WriteFile(file, []byte(source), 0600)
-
-	if err != nil {
-		return err
-	}
-
-	defer os.Remove(file)
-
-	bs, err := exec.Command("tsc", "--noEmit", file).Output()
-
-	if err != nil {
-		return fmt.Errorf("%s:\n\n%s", bs, source)
+	if err := typecheck(source); err != nil {
+		return fmt.Errorf("%w:\n\n%s", err, source)
 	}
 
 	return nil
@@ -93,6 +83,67 @@ func typecheckValue[T any](v T, os ...Option) error {
 	return typecheckSource(source)
 }
 
+// randomValue returns a random value of `typ`, nil and empty values are as
+// likely as populated ones. Values nested deeper than `depth` are zero.
+func randomValue(r *rand.Rand, typ reflect.Type, depth int) reflect.Value {
+	v := reflect.New(typ).Elem()
+
+	if depth <= 0 {
+		return v
+	}
+
+	if typ == typeOfTime {
+		v.Set(reflect.ValueOf(time.Unix(r.Int63n(1<<32), 0).UTC()))
+		return v
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		v.SetBool(r.Intn(2) == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(r.Int63n(100) - 50)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(uint64(r.Int63n(100)))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(r.Float64())
+	case reflect.String:
+		v.SetString([]string{"", "a", "test", "\"quoted\""}[r.Intn(4)])
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			v.Index(i).Set(randomValue(r, typ.Elem(), depth-1))
+		}
+	case reflect.Slice:
+		if n := r.Intn(4) - 1; n >= 0 {
+			v.Set(reflect.MakeSlice(typ, n, n))
+
+			for i := 0; i < n; i++ {
+				v.Index(i).Set(randomValue(r, typ.Elem(), depth-1))
+			}
+		}
+	case reflect.Map:
+		if n := r.Intn(4) - 1; n >= 0 {
+			v.Set(reflect.MakeMap(typ))
+
+			for i := 0; i < n; i++ {
+				v.SetMapIndex(randomValue(r, typ.Key(), depth-1), randomValue(r, typ.Elem(), depth-1))
+			}
+		}
+	case reflect.Pointer:
+		if r.Intn(2) == 1 {
+			v.Set(reflect.New(typ.Elem()))
+			v.Elem().Set(randomValue(r, typ.Elem(), depth-1))
+		}
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			if f := typ.Field(i); f.IsExported() {
+				v.Field(i).Set(randomValue(r, f.Type, depth-1))
+			}
+		}
+	}
+
+	return v
+}
+
 func TestBool(t *testing.T) {
 	t.Run("bool", func(t *testing.T) {
 		x := true
@@ -102,6 +153,19 @@ func TestBool(t *testing.T) {
 }
 
 func TestInterface(t *testing.T) {
+	t.Run("pointer to interface", func(t *testing.T) {
+		var x *error
+		var y *any
+
+		g := New()
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), "any")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(y)), "any")
+		AssertEqual(t, g.EmitType(TypeScriptEmitter{}, reflect.TypeOf(y)), "any")
+		AssertEqual(t, New(WithErrorAs("string")).TypeOf(reflect.TypeOf(x)), "string | null")
+		AssertNoError(t, typecheckValue(x))
+	})
+
 	t.Run("reflect.Interface", func(t *testing.T) {
 		type S struct {
 			A interface{}
@@ -167,6 +231,17 @@ func TestArrays(t *testing.T) {
 
 		AssertNoError(t, typecheckValue(x))
 	})
+
+	t.Run("readonly fixed arrays", func(t *testing.T) {
+		var x [3]int
+		var y []int
+
+		g := New(WithReadonlyFixedArrays())
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), "readonly [number, number, number]")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(y)), "number[] | null")
+		AssertNoError(t, typecheckValue(x, WithReadonlyFixedArrays()))
+	})
 }
 
 func TestSlices(t *testing.T) {
@@ -181,6 +256,67 @@ func TestSlices(t *testing.T) {
 
 		AssertNoError(t, typecheckValue(x))
 	})
+
+	t.Run("nil slice should be typed as nullable", func(t *testing.T) {
+		var x []int
+
+		AssertEqual(t, New().TypeOf(reflect.TypeOf(x)), "number[] | null")
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("non-nil slice with non-null collections", func(t *testing.T) {
+		x := make([]int, 9)
+
+		AssertEqual(t, New(WithNonNullCollections()).TypeOf(reflect.TypeOf(x)), "number[]")
+		AssertNoError(t, typecheckValue(x, WithNonNullCollections()))
+	})
+
+	t.Run("nil slice with non-null collections", func(t *testing.T) {
+		var x []int
+
+		AssertError(t, typecheckValue(x, WithNonNullCollections()))
+	})
+
+	t.Run("readonly arrays", func(t *testing.T) {
+		x := [][]int{{1}, nil}
+
+		g := New(WithReadonlyArrays())
+
+		AssertEqual(t, New().TypeOf(reflect.TypeOf(x)), "(number[] | null)[] | null")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), "readonly (readonly number[] | null)[] | null")
+		AssertNoError(t, typecheckValue(x, WithReadonlyArrays()))
+	})
+
+	t.Run("slice of pointers groups the element type", func(t *testing.T) {
+		n := 1
+		x := []*int{&n, nil}
+
+		AssertEqual(t, New().TypeOf(reflect.TypeOf(x)), "(number | null)[] | null")
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("pointer to slice is not grouped", func(t *testing.T) {
+		x := &[]int{1}
+
+		AssertEqual(t, New().TypeOf(reflect.TypeOf(x)), "number[] | null")
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("slice of funcs groups the element type", func(t *testing.T) {
+		var x []func(int) string
+
+		AssertEqual(t, New().TypeOf(reflect.TypeOf(x)), "((arg0: number) => string)[] | null")
+	})
+
+	t.Run("slice of typed unions groups the element type", func(t *testing.T) {
+		x := []Status{"active"}
+
+		g := New(WithTyper(reflect.TypeOf(Status("")), func(*Generator, reflect.Type, bool) string {
+			return `"active" | "inactive"`
+		}))
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), `("active" | "inactive")[] | null`)
+	})
 }
 
 func TestMaps(t *testing.T) {
@@ -197,9 +333,61 @@ func TestMaps(t *testing.T) {
 
 		AssertNoError(t, typecheckValue(x))
 	})
+
+	t.Run("non-nil map with non-null collections", func(t *testing.T) {
+		x := map[string]int{
+			"a": 1,
+		}
+
+		AssertEqual(t, New(WithNonNullCollections()).TypeOf(reflect.TypeOf(x)), "{ [key in (string)]: (number) }")
+		AssertNoError(t, typecheckValue(x, WithNonNullCollections()))
+	})
+}
+
+type internalPointer struct {
+	C string
+}
+
+type Ping struct {
+	Pong *Pong `json:"pong"`
+}
+
+type Pong struct {
+	Ping Ping `json:"ping"`
 }
 
 func TestStructs(t *testing.T) {
+	t.Run("empty struct", func(t *testing.T) {
+		var x struct{}
+
+		AssertEqual(t, New().TypeOf(reflect.TypeOf(x)), "{ }")
+		AssertEqual(t, New(WithEmptyRecords()).TypeOf(reflect.TypeOf(x)), "Record<string, never>")
+		AssertNoError(t, typecheckValue(x, WithEmptyRecords()))
+	})
+
+	t.Run("empty struct fields and map values", func(t *testing.T) {
+		type Empty struct{}
+
+		type S struct {
+			A struct{}
+			B Empty
+			C map[string]struct{}
+		}
+
+		x := S{C: map[string]struct{}{"a": {}}}
+
+		g := New(WithEmptyRecords())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `type Empty = Record<string, never>
+interface S { "A": Record<string, never>; "B": Empty; "C": { [key in (string)]: (Record<string, never>) } | null; }`)
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+	})
+
 	t.Run("anonymous struct", func(t *testing.T) {
 		var x struct {
 			A string
@@ -255,6 +443,86 @@ func TestStructs(t *testing.T) {
 		AssertNoError(t, typecheckValue(x))
 	})
 
+	t.Run("tagged embedded structs", func(t *testing.T) {
+		type Meta struct {
+			Version int
+		}
+
+		type Tagged struct {
+			Meta `json:"meta"`
+			A    int
+		}
+
+		type Untagged struct {
+			Meta
+			A int
+		}
+
+		type Yaml struct {
+			*Meta `yaml:"meta"`
+		}
+
+		g := New(WithTagName("yaml"))
+		g.Add(reflect.TypeOf(Tagged{}))
+		g.Add(reflect.TypeOf(Untagged{}))
+		g.Add(reflect.TypeOf(Yaml{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface Meta { "Version": number; }
+interface Tagged { "meta": Meta; "A": number; }
+interface Untagged { "Version": number; "A": number; }
+interface Yaml { "meta": Meta | null; }`)
+
+		source, err := programOfGenerator(g, Tagged{})
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("tagged omitempty embedded pointer structs", func(t *testing.T) {
+		type Meta struct {
+			Version int `json:"version"`
+		}
+
+		type Config struct {
+			*Meta `json:"meta,omitempty"`
+			Name  string `json:"name"`
+		}
+
+		g := New()
+		g.Add(reflect.TypeOf(Config{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface Config { "meta"?: Meta; "name": string; }
+interface Meta { "version": number; }`)
+
+		for _, x := range []Config{{}, {Meta: &Meta{Version: 1}}} {
+			source, err := programOfGenerator(g, x)
+
+			AssertNoError(t, err)
+			AssertNoError(t, typecheckSource(source))
+		}
+	})
+
+	t.Run("unexported embedded structs", func(t *testing.T) {
+		type internal struct {
+			A int
+			b int
+		}
+
+		type S struct {
+			internal
+			*internalPointer
+			B int
+		}
+
+		var x S
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.Declarations()[0].Type, `{ "A": number; "C"?: string; "B": number; }`)
+		AssertNoError(t, typecheckValue(x))
+	})
+
 	t.Run("cyclical struct", func(t *testing.T) {
 		type c struct {
 			A int
@@ -289,6 +557,58 @@ func TestStructs(t *testing.T) {
 		AssertNoError(t, typecheckValue(x))
 	})
 
+	t.Run("cyclical map struct", func(t *testing.T) {
+		type N struct {
+			Kids map[string]N `json:"kids"`
+		}
+
+		type M struct {
+			Kids map[string][]M `json:"kids"`
+		}
+
+		x := N{Kids: map[string]N{"a": {}}}
+		y := M{Kids: map[string][]M{"a": {{}}}}
+
+		g := New(WithFlatten())
+		g.Add(reflect.TypeOf(x))
+		g.Add(reflect.TypeOf(y))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface M { "kids": { [key in (string)]: (M[] | null) } | null; }
+interface N { "kids": { [key in (string)]: (N) } | null; }`)
+		AssertNoError(t, typecheckValue(x, WithFlatten()))
+		AssertNoError(t, typecheckValue(y, WithFlatten()))
+	})
+
+	t.Run("mutually cyclical structs", func(t *testing.T) {
+		var x Ping
+
+		g := New(WithFlatten())
+		g.Add(reflect.TypeOf(&x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface Ping { "pong": { "ping": Ping; } | null; }`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(Pong{})), `{ "ping": Ping; }`)
+		AssertNoError(t, typecheckValue(Ping{Pong: &Pong{}}, WithFlatten()))
+	})
+
+	t.Run("fields after cyclical fields", func(t *testing.T) {
+		type S1 struct {
+			A int
+		}
+
+		type S2 struct {
+			R *S2
+			S S1
+		}
+
+		var x S2
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S1 { "A": number; }
+interface S2 { "R": S2 | null; "S": S1; }`)
+	})
+
 	t.Run("nested cyclical struct", func(t *testing.T) {
 		type S1 struct {
 			A int
@@ -367,320 +687,2920 @@ func TestStructs(t *testing.T) {
 		AssertNoError(t, typecheckValue(x))
 	})
 
-	t.Run("omitempty struct tags", func(t *testing.T) {
-		type S1 struct {
-			A int  `json:"a"`
-			B *int `json:"b,omitempty"`
-		}
-
-		type S2 struct {
-			A int   `json:"a"`
-			B []int `json:"b,omitempty"`
+	t.Run("string pointer struct tags", func(t *testing.T) {
+		type S struct {
+			A *int64  `json:"a,string"`
+			B *int64  `json:"b,string,omitempty"`
+			C int64   `json:"c,omitempty,string"`
+			D []int64 `json:"d,string"`
 		}
 
-		type S3 struct {
-			A int         `json:"a"`
-			B map[int]int `json:"b,omitempty"`
-		}
+		var x S
 
-		type S4 struct {
-			A int `json:"a"`
-			B *S3 `json:"b,omitempty"`
-		}
+		g := New()
+		g.Add(reflect.TypeOf(x))
 
-		type S5 struct {
-			A int `json:"a"`
-			B int `json:"b,omitempty"`
-		}
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a": string | null; "b"?: string; "c"?: string; "d": number[] | null; }`)
+		AssertNoError(t, typecheckValue(x))
 
-		var x S1
-		var y S2
-		var z S3
-		var q S4
-		var f S5
+		i := int64(10)
+		x.A, x.B, x.C = &i, &i, i
 
 		AssertNoError(t, typecheckValue(x))
-		AssertNoError(t, typecheckValue(y))
-		AssertNoError(t, typecheckValue(z))
-		AssertNoError(t, typecheckValue(q))
-		AssertNoError(t, typecheckValue(f))
 	})
 
-	t.Run("struct name collision", func(t *testing.T) {
-		g := New()
-
+	t.Run("field terminator", func(t *testing.T) {
 		type S struct {
 			A int
-			B *int
+			B struct{ C string }
 		}
 
 		var x S
 
+		g := New(WithFieldTerminator(","))
 		g.Add(reflect.TypeOf(x))
 
-		{
-			type S struct {
-				C int
-				D *int
-			}
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A": number, "B": { "C": string, }, }`)
+		AssertEqual(t, g.Emit(TypeScriptEmitter{}), g.DeclarationsTypeScript())
 
-			var x S
+		source, err := programOfGenerator(g, x)
 
-			g.Add(reflect.TypeOf(x))
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+
+		defer func() {
+			AssertEqual(t, recover(), any(`tsreflect: unknown field terminator "|"`))
+		}()
+
+		New(WithFieldTerminator("|"))
+	})
+
+	t.Run("tag names take precedence over field names", func(t *testing.T) {
+		type S struct {
+			Id    int    `json:"ID"`
+			Name  string `json:"userName"`
+			Dash  int    `json:"-,"`
+			Email string `json:",omitempty"`
+			Bad   int    `json:"a\\b"`
 		}
 
+		x := S{Id: 1, Name: "a", Dash: 2}
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "ID": number; "userName": string; "-": number; "Email"?: string; "Bad": number; }`)
+
 		source, err := programOfGenerator(g, x)
 
 		AssertNoError(t, err)
 		AssertNoError(t, typecheckSource(source))
 	})
 
-	t.Run("circular struct name collision", func(t *testing.T) {
-		g := New()
+	t.Run("include omitted fields", func(t *testing.T) {
+		type Secret struct {
+			Key string
+		}
 
 		type S struct {
-			A int
-			B *S
+			A      int
+			Secret Secret `json:"-"`
+			B      string `json:"b"`
+			Name   string `json:"-"`
+			Other  string `json:"Name"`
 		}
 
-		var x S
+		x := S{Secret: Secret{Key: "key"}}
 
+		g := New()
 		g.Add(reflect.TypeOf(x))
 
-		{
-			type S struct {
-				C int
-				D *S
-			}
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A": number; "b": string; "Name": string; }`)
 
-			var x S
+		g = New(WithIncludeOmitted())
+		g.Add(reflect.TypeOf(x))
 
-			g.Add(reflect.TypeOf(x))
-		}
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A": number; readonly "Secret"?: Secret; "b": string; "Name": string; }
+interface Secret { "Key": string; }`)
 
 		source, err := programOfGenerator(g, x)
 
 		AssertNoError(t, err)
 		AssertNoError(t, typecheckSource(source))
 	})
-}
 
-func TestUnsupported(t *testing.T) {
-	t.Run("complex64", func(t *testing.T) {
-		x := complex64(10 + 20i)
+	t.Run("all fields optional", func(t *testing.T) {
+		type S struct {
+			A int    `json:"a"`
+			B *int   `json:"b"`
+			C []int  `json:"c,omitempty"`
+			D string `json:"d,string"`
+		}
 
-		AssertError(t, typecheckValue(x))
-	})
+		var x S
+
+		g := New(WithAllFieldsOptional())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a"?: number; "b"?: number | null; "c"?: number[]; "d"?: string; }`)
+		AssertNoError(t, typecheckValue(x, WithAllFieldsOptional()))
+		AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()+"\nconst test: S = {}"))
+	})
+
+	t.Run("dotted tag nesting", func(t *testing.T) {
+		type S struct {
+			Host    string `json:"server.host"`
+			Port    int    `json:"server.port"`
+			Name    string `json:"name"`
+			Timeout int    `json:"server.limits.timeout,omitempty"`
+			Level   string `json:"log.level,omitempty"`
+		}
+
+		g := New(WithDottedTagNesting())
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "server": { "host": string; "port": number; "limits"?: { "timeout"?: number; }; }; "name": string; "log"?: { "level"?: string; }; }`)
+		AssertEqual(t, g.Emit(TypeScriptEmitter{}), `interface S { "server": { "host": string; "port": number; "limits"?: { "timeout"?: number; }; }; "name": string; "log"?: { "level"?: string; }; }`)
+
+		g = New()
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "server.host": string; "server.port": number; "name": string; "server.limits.timeout"?: number; "log.level"?: string; }`)
+	})
+
+	t.Run("dotted tag nesting clashes", func(t *testing.T) {
+		type S struct {
+			A   int    `json:"a"`
+			AB  string `json:"a.b"`
+			C   string `json:"c.d"`
+			CD  int    `json:"c.d.e"`
+			CDF int    `json:"c.d.f"`
+		}
+
+		var warnings []string
+
+		g := New(WithDottedTagNesting())
+		g.warn = func(format string, a ...any) {
+			warnings = append(warnings, fmt.Sprintf(format, a...))
+		}
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a": number; "a.b": string; "c": { "d": string; "d.e": number; "d.f": number; }; }`)
+		AssertEqual(t, strings.Join(warnings, "\n"), fmt.Sprintf(`tsreflect: WARNING field S: property "a" of type %[1]q clashes with the dotted properties nested in it, they are not nested.
+tsreflect: WARNING field S: property "c.d" of type %[1]q clashes with the dotted properties nested in it, they are not nested.`, reflect.TypeOf(S{}).String()))
+		AssertEqual(t, g.Emit(TypeScriptEmitter{}), g.DeclarationsTypeScript())
+		AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()))
+	})
+
+	t.Run("defensive optionals", func(t *testing.T) {
+		type S struct {
+			A *int   `json:"a,omitempty"`
+			B *int   `json:"b"`
+			C *int   `json:"c,omitempty,string"`
+			D int    `json:"d,omitempty"`
+			E []int  `json:"e,omitempty"`
+			F string `json:"f"`
+		}
+
+		n := 1
+
+		g := New(WithDefensiveOptionals())
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a"?: number | null | undefined; "b": number | null; "c"?: string | null | undefined; "d"?: number; "e"?: number[]; "f": string; }`)
+		AssertEqual(t, g.Emit(TypeScriptEmitter{}), g.DeclarationsTypeScript())
+		AssertNoError(t, typecheckValue(S{}, WithDefensiveOptionals()))
+		AssertNoError(t, typecheckValue(S{A: &n, B: &n, C: &n, D: 1, E: []int{1}}, WithDefensiveOptionals()))
+		AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()+"\nconst test: S = { a: null, b: null, c: undefined, f: \"\" }"))
+	})
+
+	t.Run("ts type override", func(t *testing.T) {
+		type S struct {
+			Counts map[string]any `json:"counts" ts:"type=Record<string, number>"`
+			Mode   string         `json:"mode,omitempty" ts:"type=\"fast\" | \"slow\""`
+			Next   *int           `json:"next,omitempty" ts:"type=number"`
+			Name   string         `json:"name" ts:""`
+		}
+
+		x := S{Counts: map[string]any{"a": 1}, Mode: "fast"}
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "counts": Record<string, number>; "mode"?: "fast" | "slow"; "next"?: number; "name": string; }`)
+		AssertEqual(t, g.Emit(TypeScriptEmitter{}), g.DeclarationsTypeScript())
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+		AssertError(t, typecheckSource(g.DeclarationsTypeScript()+`
+const test: S = { counts: { a: "b" }, name: "" }`))
+	})
+
+	t.Run("readonly fields", func(t *testing.T) {
+		type S struct {
+			ID     int    `json:"id" ts:"readonly"`
+			Name   string `json:"name,omitempty" ts:"readonly, type=\"a\" | \"b\""`
+			Inline struct {
+				A int `json:"a"`
+			} `json:"inline"`
+			Note string `json:"note"`
+		}
+
+		x := S{ID: 1, Name: "a"}
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { readonly "id": number; readonly "name"?: "a" | "b"; "inline": { "a": number; }; "note": string; }`)
+
+		g = New(WithReadonly())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { readonly "id": number; readonly "name"?: "a" | "b"; readonly "inline": { readonly "a": number; }; readonly "note": string; }`)
+		AssertEqual(t, g.Emit(TypeScriptEmitter{}), g.DeclarationsTypeScript())
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+		AssertError(t, typecheckSource(source+"\ntest.note = \"\""))
+	})
+
+	t.Run("compact null", func(t *testing.T) {
+		type S struct {
+			A *int           `json:"a"`
+			B []*int         `json:"b"`
+			C map[string]int `json:"c"`
+			D *int           `json:"d,string"`
+			E int            `json:"e"`
+		}
+
+		n := 1
+		x := S{A: &n, B: []*int{&n}, C: map[string]int{"a": 1}, D: &n}
+
+		g := New(WithCompactNull())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a"?: number; "b"?: number[]; "c"?: { [key in (string)]: (number) }; "d"?: string; "e": number; }`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(&n)), "number")
+		AssertNoError(t, typecheckValue(x, WithCompactNull()))
+		AssertError(t, typecheckValue(S{}, WithCompactNull()))
+	})
+
+	t.Run("maximal nullability", func(t *testing.T) {
+		type S struct {
+			A *int
+			B *int `json:",omitempty"`
+			C *int `json:",string"`
+			D int  `json:",omitempty"`
+			E []int
+		}
+
+		var x S
+
+		g := New(WithMaximalNullability())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A"?: number | null; "B"?: number | null; "C"?: string | null; "D"?: number; "E": number[] | null; }`)
+
+		source := fmt.Sprintf("%s\nconst test: S = { A: null, E: null }", g.DeclarationsTypeScript())
+
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("default struct tags", func(t *testing.T) {
+		type S struct {
+			A int    `default:"10"`
+			B *int   `default:"10"`
+			C string `json:"c" default:"c"`
+			D int
+		}
+
+		var x S
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A": number; "B": number | null; "c": string; "D": number; }`)
+
+		g = New(WithDefaultTagOptional())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A"?: number; "B"?: number; "c"?: string; "D": number; }`)
+
+		source := fmt.Sprintf("%s\nconst test: S = { D: 1 }", g.DeclarationsTypeScript())
+
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("omitempty struct tags", func(t *testing.T) {
+		type S1 struct {
+			A int  `json:"a"`
+			B *int `json:"b,omitempty"`
+		}
+
+		type S2 struct {
+			A int   `json:"a"`
+			B []int `json:"b,omitempty"`
+		}
+
+		type S3 struct {
+			A int         `json:"a"`
+			B map[int]int `json:"b,omitempty"`
+		}
+
+		type S4 struct {
+			A int `json:"a"`
+			B *S3 `json:"b,omitempty"`
+		}
+
+		type S5 struct {
+			A int `json:"a"`
+			B int `json:"b,omitempty"`
+		}
+
+		var x S1
+		var y S2
+		var z S3
+		var q S4
+		var f S5
+
+		AssertNoError(t, typecheckValue(x))
+		AssertNoError(t, typecheckValue(y))
+		AssertNoError(t, typecheckValue(z))
+		AssertNoError(t, typecheckValue(q))
+		AssertNoError(t, typecheckValue(f))
+	})
+
+	t.Run("omitempty slices are optional and non-null", func(t *testing.T) {
+		type S struct {
+			A int   `json:"a"`
+			B []int `json:"b,omitempty"`
+		}
+
+		var x S
+		y := S{B: []int{}}
+		z := S{B: []int{1, 2}}
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a": number; "b"?: number[]; }`)
+
+		for _, v := range []S{x, y, z} {
+			value, err := json.Marshal(v)
+
+			AssertNoError(t, err)
+			AssertNoError(t, typecheckSource(fmt.Sprintf("%s\nconst test: S = %s", g.DeclarationsTypeScript(), value)))
+		}
+	})
+
+	t.Run("pointer and omitempty combinations", func(t *testing.T) {
+		type S struct {
+			Value         int  `json:"value"`
+			OmitValue     int  `json:"omitValue,omitempty"`
+			Pointer       *int `json:"pointer"`
+			OmitPointer   *int `json:"omitPointer,omitempty"`
+			PointerToZero *int `json:"pointerToZero,omitempty"`
+		}
+
+		zero, one := 0, 1
+
+		g := New()
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "value": number; "omitValue"?: number; "pointer": number | null; "omitPointer"?: number; "pointerToZero"?: number; }`)
+
+		for _, x := range []S{{}, {Value: 1, OmitValue: 1, Pointer: &one, OmitPointer: &one, PointerToZero: &zero}} {
+			value, err := json.Marshal(x)
+
+			AssertNoError(t, err)
+			AssertNoError(t, typecheckSource(fmt.Sprintf("%s\nconst test: S = %s", g.DeclarationsTypeScript(), value)))
+		}
+
+		value, err := json.Marshal(S{PointerToZero: &zero})
+
+		AssertNoError(t, err)
+		AssertEqual(t, string(value), `{"value":0,"pointer":null,"pointerToZero":0}`)
+	})
+
+	t.Run("omitempty maps are optional and non-null", func(t *testing.T) {
+		type S struct {
+			A int            `json:"a"`
+			M map[string]int `json:"m,omitempty"`
+		}
+
+		var x S
+		y := S{M: map[string]int{}}
+		z := S{M: map[string]int{"a": 1}}
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a": number; "m"?: { [key in (string)]: (number) }; }`)
+		AssertEqual(t, g.TypeOfField(reflect.TypeOf(x).Field(1)), `{ [key in (string)]: (number) }`)
+
+		for _, v := range []S{x, y, z} {
+			value, err := json.Marshal(v)
+
+			AssertNoError(t, err)
+			AssertNoError(t, typecheckSource(fmt.Sprintf("%s\nconst test: S = %s", g.DeclarationsTypeScript(), value)))
+		}
+	})
+
+	t.Run("omitempty array struct tags", func(t *testing.T) {
+		type S struct {
+			A [3]int `json:"a,omitempty"`
+			B [0]int `json:"b,omitempty"`
+		}
+
+		var x S
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a": [number, number, number]; "b"?: []; }`)
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("omitempty nested struct tags", func(t *testing.T) {
+		type S1 struct {
+			A int
+		}
+
+		type S2 struct {
+			B S1 `json:"b,omitempty"`
+		}
+
+		var x S2
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), "S2")
+		AssertEqual(t, g.Declarations()[1].Type, `{ "b": S1; }`)
+	})
+
+	t.Run("omitempty nullable struct tags", func(t *testing.T) {
+		type S struct {
+			A *int   `json:"a,omitempty"`
+			B []int  `json:"b,omitempty"`
+			C string `json:"c,omitempty"`
+		}
+
+		var x S
+
+		g := New(WithOmitemptyNullable())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a"?: number | null; "b"?: number[]; "c"?: string; }`)
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source+"\nconst nullable: S = { a: null }"))
+	})
+
+	t.Run("struct name collision", func(t *testing.T) {
+		g := New()
+
+		type S struct {
+			A int
+			B *int
+		}
+
+		var x S
+
+		g.Add(reflect.TypeOf(x))
+
+		{
+			type S struct {
+				C int
+				D *int
+			}
+
+			var x S
+
+			g.Add(reflect.TypeOf(x))
+		}
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("circular struct name collision", func(t *testing.T) {
+		g := New()
+
+		type S struct {
+			A int
+			B *S
+		}
+
+		var x S
+
+		g.Add(reflect.TypeOf(x))
+
+		{
+			type S struct {
+				C int
+				D *S
+			}
+
+			var x S
+
+			g.Add(reflect.TypeOf(x))
+		}
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+	})
+}
+
+func TestPruneUnused(t *testing.T) {
+	type S1 struct {
+		A int
+	}
+
+	type S2 struct {
+		B S1
+	}
+
+	type S3 struct {
+		C S2
+		D S1
+	}
+
+	typer := func(g *Generator, typ reflect.Type, optional bool) string {
+		return `{ "B": { "A": number; }; }`
+	}
+
+	t.Run("type only inlined by typer", func(t *testing.T) {
+		var x S2
+
+		g := New(WithTyper(reflect.TypeOf(x), typer))
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S1 { "A": number; }`)
+
+		g = New(WithPruneUnused(), WithTyper(reflect.TypeOf(x), typer))
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), "")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), `{ "B": { "A": number; }; }`)
+	})
+
+	t.Run("referenced types are kept", func(t *testing.T) {
+		var x S3
+
+		g := New(WithPruneUnused(), WithTyper(reflect.TypeOf(S2{}), typer))
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S1 { "A": number; }
+interface S3 { "C": { "B": { "A": number; }; }; "D": S1; }`)
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("types referenced through inlined types are kept", func(t *testing.T) {
+		var x S2
+
+		g := New(WithPruneUnused())
+		g.SetInline(reflect.TypeOf(x), true)
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S1 { "A": number; }`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), `{ "B": S1; }`)
+	})
+}
+
+func TestFuncs(t *testing.T) {
+	t.Run("func", func(t *testing.T) {
+		g := New()
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(func() {})), "(() => void)")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(func(int, *string) (string, error) { return "", nil })), "((arg0: number, arg1: string | null) => string)")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(func(string, ...int) (int, bool) { return 0, false })), "((arg0: string, ...arg1: number[]) => [number, boolean])")
+	})
+
+	t.Run("map of funcs", func(t *testing.T) {
+		type S struct {
+			Handlers map[string]func(int) string `json:"handlers"`
+		}
+
+		var x S
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		source := fmt.Sprintf("%s\nconst test: S = { handlers: { double: (n: number) => String(n * 2) } }", g.DeclarationsTypeScript())
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "handlers": { [key in (string)]: (((arg0: number) => string)) } | null; }`)
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("declare struct params", func(t *testing.T) {
+		type User struct {
+			Name string
+		}
+
+		var x func(User) (*User, error)
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface User { "Name": string; }`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), "((arg0: User) => User | null)")
+	})
+
+	t.Run("error as result", func(t *testing.T) {
+		g := New(WithErrorAsResult())
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(func() (string, error) { return "", nil })), `(() => { "data": string | null; "error": string | null; })`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(func() (int, bool, error) { return 0, false, nil })), `(() => { "data": [number, boolean] | null; "error": string | null; })`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(func() error { return nil })), `(() => { "error": string | null; })`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(func() string { return "" })), "(() => string)")
+	})
+
+	t.Run("optional pointer params", func(t *testing.T) {
+		type Options struct {
+			Limit int
+		}
+
+		g := New(WithOptionalPointerParams())
+
+		var warning string
+		g.warn = func(s string, a ...any) {
+			warning = fmt.Sprintf(s, a...)
+		}
+
+		var x func(string, *Options) error
+		var y func(*Options, ...int)
+		var z func(*Options, string)
+
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), "((arg0: string, arg1?: Options) => void)")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(y)), "((arg0?: Options, ...arg1: number[]) => void)")
+		AssertEqual(t, warning, "")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(z)), "((arg0: Options | null, arg1: string) => void)")
+		AssertEqual(t, warning, "tsreflect: WARNING field arg0: pointer parameter can not be optional since it is followed by a required parameter.")
+	})
+}
+
+type UserID string
+
+type OrderID string
+
+func TestBrandedStrings(t *testing.T) {
+	type Order struct {
+		ID   OrderID `json:"id"`
+		User UserID  `json:"user"`
+		Note string  `json:"note"`
+	}
+
+	var x Order
+
+	g := New(WithBrandedStrings())
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Order { "id": OrderID; "user": UserID; "note": string; }
+type OrderID = string & { readonly __brand: "OrderID" }
+type UserID = string & { readonly __brand: "UserID" }`)
+
+	source := g.DeclarationsTypeScript() + `
+const user = "1" as UserID
+const order: Order = { id: "2" as OrderID, user, note: "" }`
+
+	AssertNoError(t, typecheckSource(source))
+	AssertError(t, typecheckSource(source+"\nconst bad: Order = { id: user, user, note: \"\" }"))
+}
+
+func TestBrandedUnsigned(t *testing.T) {
+	type Uint struct {
+		A int
+	}
+
+	type S struct {
+		Count  uint    `json:"count"`
+		Sizes  []uint8 `json:"sizes"`
+		Offset int     `json:"offset"`
+		Other  Uint    `json:"other"`
+	}
+
+	var x S
+
+	g := New(WithBrandedUnsigned())
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "count": Uint; "sizes": string | null; "offset": number; "other": Uint2; }
+type Uint = number & { __uint: void }
+interface Uint2 { "A": number; }`)
+
+	AssertEqual(t, New().TypeOf(reflect.TypeOf(uint(0))), "number")
+
+	source := g.DeclarationsTypeScript() + `
+const s: S = { count: 1 as Uint, sizes: null, offset: -1, other: { A: 1 } }`
+
+	AssertNoError(t, typecheckSource(source))
+	AssertError(t, typecheckSource(source+"\nconst bad: S = { count: -1, sizes: null, offset: 1, other: { A: 1 } }"))
+}
+
+func TestTimeAsBranded(t *testing.T) {
+	type Event struct {
+		At      time.Time  `json:"at"`
+		Updated *time.Time `json:"updated"`
+	}
+
+	x := Event{At: time.Now()}
+
+	g := New(WithTimeAsBranded())
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Event { "at": ISODateString; "updated": ISODateString | null; }
+type ISODateString = string & { __iso: void }`)
+
+	value, err := json.Marshal(x)
+	AssertNoError(t, err)
+
+	source := g.DeclarationsTypeScript() + fmt.Sprintf(`
+const value = %s
+const event: Event = { at: value.at as ISODateString, updated: null }`, value)
+
+	AssertNoError(t, typecheckSource(source))
+	AssertError(t, typecheckSource(source+`
+const bad: Event = { at: "yesterday", updated: null }`))
+}
+
+func TestTimeAsBrandedReferences(t *testing.T) {
+	type Ev struct {
+		At time.Time `json:"at"`
+	}
+
+	g := New(WithTimeAsBranded(), WithTopologicalOrder())
+	g.Add(reflect.TypeOf(Ev{}))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `type ISODateString = string & { __iso: void }
+interface Ev { "at": ISODateString; }`)
+	AssertEqual(t, string(g.Manifest()), `[{"name":"ISODateString","kind":"alias","function":false,"dependencies":[]},{"name":"Ev","kind":"interface","function":false,"package":"github.com/olahol/tsreflect","type":"Ev","dependencies":["ISODateString"]}]`)
+}
+
+func TestUnsupported(t *testing.T) {
+	t.Run("complex64", func(t *testing.T) {
+		x := complex64(10 + 20i)
+
+		AssertError(t, typecheckValue(x))
+	})
 
 	t.Run("complex128", func(t *testing.T) {
 		x := 10 + 20i
 
-		AssertError(t, typecheckValue(x))
-	})
+		AssertError(t, typecheckValue(x))
+	})
+
+	t.Run("chan", func(t *testing.T) {
+		x := make(chan struct{})
+
+		AssertError(t, typecheckValue(x))
+	})
+
+	t.Run("func", func(t *testing.T) {
+		x := func() {}
+
+		AssertError(t, typecheckValue(x))
+	})
+
+	t.Run("unsafe pointer", func(t *testing.T) {
+		i := 10
+		x := unsafe.Pointer(&i)
+
+		AssertError(t, typecheckValue(x))
+	})
+
+	t.Run("unsupported kinds should be typed as never", func(t *testing.T) {
+		g := New()
+
+		var warning string
+		g.warn = func(s string, a ...any) {
+			warning = fmt.Sprintf(s, a...)
+		}
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(make(chan int))), "never")
+		AssertEqual(t, warning, `tsreflect: WARNING type "chan int" of kind chan can not be marshaled, using never.`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(complex64(0))), "never")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(unsafe.Pointer(nil))), "never")
+	})
+}
+
+type StringUnion string
+
+func (s StringUnion) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", s)), nil
+}
+
+func (StringUnion) TypeScriptType(*Generator, bool) string {
+	return fmt.Sprintf("%q | %q", "test1", "test2")
+}
+
+type NonNullSlice[T any] []T
+
+func (s NonNullSlice[T]) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]T(s))
+}
+
+func (s NonNullSlice[T]) TypeScriptType(g *Generator, optional bool) string {
+	typ := reflect.TypeOf(s)
+
+	return fmt.Sprintf("Array<%s>", typ.Elem())
+}
+
+type Base32Slice []byte
+
+func (s Base32Slice) MarshalJSON() ([]byte, error) {
+	out := base32.StdEncoding.EncodeToString(s)
+
+	return []byte(fmt.Sprintf("%q", out)), nil
+}
+
+func (s Base32Slice) TypeScriptType(*Generator, bool) string {
+	return "string"
+}
+
+type Shape interface {
+	TypeScriptTyper
+	Area() float64
+}
+
+type IDList []int
+
+func (l IDList) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]int(l))
+}
+
+func TestCustomTypes(t *testing.T) {
+	t.Run("typer for kind", func(t *testing.T) {
+		type S struct {
+			A bool
+			B Enabled
+			C *bool
+			D string
+		}
+
+		bits := WithTyperForKind(reflect.Bool, func(g *Generator, typ reflect.Type, optional bool) string {
+			return "(0 | 1)"
+		})
+
+		g := New(bits, WithTyper(reflect.TypeOf(Enabled(false)), func(g *Generator, typ reflect.Type, optional bool) string {
+			return "boolean"
+		}))
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A": (0 | 1); "B": boolean; "C": (0 | 1) | null; "D": string; }`)
+	})
+
+	t.Run("interface embedding a typer", func(t *testing.T) {
+		type S struct {
+			Shape  Shape   `json:"shape"`
+			Shapes []Shape `json:"shapes"`
+		}
+
+		g := New()
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "shape": any; "shapes": any[] | null; }`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf((*Shape)(nil))), "any")
+	})
+
+	t.Run("array marshaler", func(t *testing.T) {
+		var x IDList
+
+		g := New(WithArrayMarshaler(reflect.TypeOf(x), "number"))
+
+		var called bool
+		g.warn = func(s string, a ...any) {
+			called = true
+		}
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), "number[]")
+		AssertEqual(t, called, false)
+		AssertNoError(t, typecheckValue(x, WithArrayMarshaler(reflect.TypeOf(x), "number")))
+	})
+
+	t.Run("union", func(t *testing.T) {
+		var x StringUnion
+
+		AssertError(t, typecheckValue(x))
+
+		x = "test2"
+
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("generics", func(t *testing.T) {
+		var x NonNullSlice[string]
+
+		AssertNoError(t, typecheckValue(x))
+
+		x = append(x, "test")
+
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("transform", func(t *testing.T) {
+		var x Base32Slice
+
+		AssertNoError(t, typecheckValue(x))
+
+		x = []byte("test")
+
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("with typer", func(t *testing.T) {
+		type S struct {
+			A string
+		}
+
+		var x S
+
+		typ := reflect.TypeOf(x)
+
+		AssertNoError(t, typecheckValue(x, WithTyper(typ, func(g *Generator, typ reflect.Type, optional bool) string {
+			return "Record<string, string>"
+		})))
+
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("optional fields", func(t *testing.T) {
+		type S struct {
+			A StringUnion          `json:"a,omitempty"`
+			B NonNullSlice[string] `json:"b,omitempty"`
+			C Date                 `json:"c,omitempty"`
+			D []byte               `json:"d,omitempty"`
+			E *big.Int             `json:"e,omitempty"`
+		}
+
+		var x S
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a"?: "test1" | "test2"; "b"?: Array<string>; "c": Date; "d"?: string; "e"?: number; }`)
+
+		x.A = "test1"
+		x.C = Date(time.Now())
+
+		AssertNoError(t, typecheckValue(x))
+	})
+}
+
+func TestBuiltin(t *testing.T) {
+	t.Run("rune", func(t *testing.T) {
+		//x := '⌘'
+		//x := []rune{'a', 'b', 'c'}
+		var x []rune
+
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("[]byte should be typed as string | null", func(t *testing.T) {
+		var x []byte
+
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("[]byte format should be configurable", func(t *testing.T) {
+		typ := reflect.TypeOf([]byte{})
+
+		AssertEqual(t, New(WithBytesAs(BytesBase64String)).TypeOf(typ), "string | null")
+		AssertEqual(t, New(WithBytesAs(BytesNonNullString)).TypeOf(typ), "string")
+		AssertEqual(t, New(WithBytesAs(BytesNumberArray)).TypeOf(typ), "number[] | null")
+
+		defer func() {
+			AssertEqual(t, recover(), any(`tsreflect: unknown bytes format "hex"`))
+		}()
+
+		New(WithBytesAs("hex"))
+	})
+
+	t.Run("http.Header should be typed as an index signature", func(t *testing.T) {
+		type S struct {
+			Headers http.Header `json:"headers"`
+			Trailer http.Header `json:"trailer,omitempty"`
+		}
+
+		x := S{Headers: http.Header{"Accept": {"text/html"}}}
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "headers": { [key: string]: string[] } | null; "trailer"?: { [key: string]: string[] }; }`)
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("time.Time should be typed as string", func(t *testing.T) {
+		var x time.Time
+
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("time.Time fields", func(t *testing.T) {
+		type S struct {
+			A time.Time
+			B *time.Time
+			C *time.Time `json:",omitempty"`
+			D time.Time  `json:",omitempty"`
+		}
+
+		var x S
+
+		g := New()
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A": string; "B": string | null; "C"?: string; "D": string; }`)
+
+		g = New(WithOmitemptyNullable())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A": string; "B": string | null; "C"?: string | null; "D": string; }`)
+
+		now := time.Now()
+		y := S{B: &now, C: &now}
+
+		AssertNoError(t, typecheckValue(x))
+		AssertNoError(t, typecheckValue(y))
+	})
+
+	t.Run("time.Time in containers", func(t *testing.T) {
+		g := New()
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf([]time.Time{})), "string[] | null")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(map[string]time.Time{})), "{ [key in (string)]: (string) } | null")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(&time.Time{})), "string | null")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf([3]time.Time{})), "[string, string, string]")
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(struct{ T time.Time }{})), `{ "T": string; }`)
+
+		now := time.Now()
+		x := struct {
+			A []time.Time
+			B map[string]time.Time
+			C *time.Time
+			D [3]time.Time
+		}{[]time.Time{now}, map[string]time.Time{"now": now}, &now, [3]time.Time{now, now, now}}
+
+		AssertNoError(t, typecheckValue(x))
+	})
+
+	t.Run("error should be configurable", func(t *testing.T) {
+		type S struct {
+			Err error `json:"err"`
+		}
+
+		var x func() (S, error)
+
+		AssertEqual(t, New().TypeOf(reflect.TypeOf(S{})), `{ "err": any; }`)
+		AssertEqual(t, New(WithErrorAs("string")).TypeOf(reflect.TypeOf(S{})), `{ "err": string; }`)
+		AssertEqual(t, New(WithErrorAs("string | null")).TypeOf(reflect.TypeOf(x)), `(() => { "err": string | null; })`)
+	})
+
+	t.Run("big.Int should be typed as 'number | null'", func(t *testing.T) {
+		x := big.NewInt(99)
+
+		AssertNoError(t, typecheckValue(x))
+	})
+}
+
+type Marshaled struct {
+	A int
+}
+
+func (Marshaled) MarshalJSON() ([]byte, error) {
+	return []byte("string"), nil
+}
+
+func TestWarning(t *testing.T) {
+	t.Run("should warn of missing typer", func(t *testing.T) {
+		var x Marshaled
+
+		g := New()
+		typ := reflect.TypeOf(x)
+
+		var called bool
+		g.warn = func(s string, a ...any) {
+			called = true
+		}
+
+		g.Add(typ)
+		g.TypeOf(typ)
+
+		AssertEqual(t, called, true)
+	})
+
+	t.Run("should not warn of missing typer", func(t *testing.T) {
+		var x Marshaled
+
+		g := New(WithNoWarnings())
+		typ := reflect.TypeOf(x)
+
+		var called bool
+		g.warn = func(s string, a ...any) {
+			called = true
+		}
+
+		g.Add(typ)
+		g.TypeOf(typ)
+
+		AssertEqual(t, called, false)
+	})
+
+	t.Run("should warn of sync types", func(t *testing.T) {
+		type Embedded struct {
+			sync.Mutex
+			A int `json:"a"`
+		}
+
+		type Field struct {
+			Mu sync.RWMutex `json:"mu"`
+		}
+
+		g := New()
+
+		var warnings []string
+		g.warn = func(s string, a ...any) {
+			warnings = append(warnings, fmt.Sprintf(s, a...))
+		}
+
+		g.Add(reflect.TypeOf(Embedded{}))
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface Embedded { "a": number; }`)
+		AssertEqual(t, len(warnings), 0)
+
+		g.Add(reflect.TypeOf(Field{}))
+		g.DeclarationsTypeScript()
+
+		AssertEqual(t, len(warnings), 1)
+		AssertEqual(t, warnings[0], `tsreflect: WARNING field Field.mu: type "sync.RWMutex" is used for synchronization and is marshaled as an empty object.`)
+		AssertNoError(t, typecheckValue(Field{}))
+	})
+
+	t.Run("should warn of properties that differ only in case", func(t *testing.T) {
+		type S struct {
+			ID    int
+			Id    int `json:"id"`
+			Email string
+		}
+
+		g := New()
+
+		var warnings []string
+		g.warn = func(s string, a ...any) {
+			warnings = append(warnings, fmt.Sprintf(s, a...))
+		}
+
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "ID": number; "id": number; "Email": string; }`)
+		AssertEqual(t, len(warnings), 1)
+		AssertEqual(t, warnings[0], fmt.Sprintf(`tsreflect: WARNING field S: properties "ID" and "id" of type %q differ only in case, encoding/json unmarshals them case-insensitively.`, reflect.TypeOf(S{}).String()))
+	})
+
+	t.Run("should warn of 64-bit integers", func(t *testing.T) {
+		type S struct {
+			ID    int64
+			Count int32
+			Size  uint64 `json:",string"`
+			Ptr   uintptr
+		}
+
+		g := New(WithPrecisionWarnings())
+
+		var warnings []string
+		g.warn = func(s string, a ...any) {
+			warnings = append(warnings, fmt.Sprintf(s, a...))
+		}
+
+		g.Add(reflect.TypeOf(S{}))
+		g.DeclarationsTypeScript()
+
+		AssertEqual(t, len(warnings), 2)
+		AssertEqual(t, warnings[0], `tsreflect: WARNING field S.ID: type "int64" loses precision as a number beyond 2^53, use the "string" tag option or a typer for it.`)
+		AssertEqual(t, warnings[1], `tsreflect: WARNING field S.Ptr: type "uintptr" loses precision as a number beyond 2^53, use the "string" tag option or a typer for it.`)
+	})
+
+	t.Run("should not warn of 64-bit integer map keys", func(t *testing.T) {
+		type S struct {
+			Names map[int64]string  `json:"names"`
+			Sizes map[uint64]uint64 `json:"sizes"`
+		}
+
+		g := New(WithPrecisionWarnings())
+
+		var warnings []string
+		g.warn = func(s string, a ...any) {
+			warnings = append(warnings, fmt.Sprintf(s, a...))
+		}
+
+		g.Add(reflect.TypeOf(S{}))
+		g.DeclarationsTypeScript()
+
+		AssertEqual(t, len(warnings), 1)
+		AssertEqual(t, warnings[0], `tsreflect: WARNING field S.sizes{}: type "uint64" loses precision as a number beyond 2^53, use the "string" tag option or a typer for it.`)
+	})
+
+	t.Run("should warn once", func(t *testing.T) {
+		type S struct {
+			ID    int64 `json:"id"`
+			Other int64 `json:"other"`
+		}
+
+		g := New(WithPrecisionWarnings())
+
+		var warnings []string
+		g.warn = func(s string, a ...any) {
+			warnings = append(warnings, fmt.Sprintf(s, a...))
+		}
+
+		g.Add(reflect.TypeOf(S{}))
+		g.DeclarationsTypeScript()
+		g.DeclarationsTypeScript()
+		g.TypeOf(reflect.TypeOf(S{}))
+
+		AssertEqual(t, len(warnings), 2)
+		AssertEqual(t, warnings[0], `tsreflect: WARNING field S.id: type "int64" loses precision as a number beyond 2^53, use the "string" tag option or a typer for it.`)
+		AssertEqual(t, warnings[1], `tsreflect: WARNING field S.other: type "int64" loses precision as a number beyond 2^53, use the "string" tag option or a typer for it.`)
+	})
+
+	t.Run("should warn with field path", func(t *testing.T) {
+		type User struct {
+			Avatar Marshaled
+		}
+
+		type S struct {
+			Users []User
+		}
+
+		var x S
+
+		g := New(WithFlatten())
+		typ := reflect.TypeOf(x)
+
+		var message string
+		g.warn = func(s string, a ...any) {
+			message = fmt.Sprintf(s, a...)
+		}
+
+		g.Add(typ)
+		g.TypeOf(typ)
+
+		AssertEqual(t, message, `tsreflect: WARNING field Users[].Avatar: json.Marshaler implemented for type "tsreflect.Marshaled" but no corresponding typer could be found.`)
+	})
+}
+
+func TestFieldOrder(t *testing.T) {
+	type E1 struct {
+		A int
+		B int `json:"b"`
+	}
+
+	type E2 struct {
+		A int
+		C int
+		F int
+	}
+
+	type E3 struct {
+		G int `json:"F"`
+	}
+
+	type S struct {
+		Z int
+		E1
+		Y int `json:"C"`
+		E2
+		E3
+		X int
+	}
+
+	var x S
+
+	g := New(WithFlatten())
+	g.Add(reflect.TypeOf(x))
+
+	value, err := json.Marshal(x)
+
+	AssertNoError(t, err)
+	AssertEqual(t, string(value), `{"Z":0,"b":0,"C":0,"F":0,"X":0}`)
+	AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), `{ "Z": number; "b": number; "C": number; "F": number; "X": number; }`)
+
+	t.Run("embedded before direct fields", func(t *testing.T) {
+		type S struct {
+			E1
+			A string
+			E2
+		}
+
+		var x S
+
+		g := New(WithFlatten())
+		g.Add(reflect.TypeOf(x))
+
+		value, err := json.Marshal(x)
+
+		AssertNoError(t, err)
+		AssertEqual(t, string(value), `{"b":0,"A":"","C":0,"F":0}`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), `{ "b": number; "A": string; "C": number; "F": number; }`)
+	})
+}
+
+func TestTagName(t *testing.T) {
+	t.Run("tag name", func(t *testing.T) {
+		type S struct {
+			A int    `toml:"a,omitempty"`
+			B string `toml:"-"`
+			C int64  `toml:"c,string" json:"json_c"`
+			D int    `json:"d,omitempty"`
+		}
+
+		var x S
+
+		g := New(WithTagName("toml"))
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a"?: number; "c": string; "d"?: number; }`)
+	})
+
+	t.Run("tag name chain", func(t *testing.T) {
+		type S struct {
+			A int    `mapstructure:"a" db:"db_a" json:"json_a"`
+			B int    `db:"b,omitempty" json:"json_b"`
+			C string `db:"-" json:"c"`
+			D string `mapstructure:"d,string" db:"-"`
+			E int    `json:"e"`
+		}
+
+		g := New(WithTagName("mapstructure"), WithTagName("db"))
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "a": number; "b"?: number; "d": string; "e": number; }`)
+
+		g = New(WithTagName("db"), WithTagName("mapstructure"))
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "db_a": number; "b"?: number; "e": number; }`)
+
+		g = New()
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "json_a": number; "json_b": number; "c": string; "D": string; "e": number; }`)
+	})
+}
+
+func TestInline(t *testing.T) {
+	type S1 struct {
+		A int
+	}
+
+	type S2 struct {
+		B S1
+	}
+
+	t.Run("inline type", func(t *testing.T) {
+		var x S2
+
+		g := New()
+		g.SetInline(reflect.TypeOf(S1{}), true)
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S2 { "B": { "A": number; }; }`)
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("declare type when flattened", func(t *testing.T) {
+		var x S2
+
+		g := New(WithFlatten())
+		g.SetInline(reflect.TypeOf(S1{}), false)
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S1 { "A": number; }`)
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), `{ "B": S1; }`)
+	})
+
+	t.Run("circular types are never inlined", func(t *testing.T) {
+		type S struct {
+			A int
+			R *S
+		}
+
+		var x S
+
+		g := New()
+		g.SetInline(reflect.TypeOf(x), true)
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), "S")
+	})
+}
+
+func TestGenerics(t *testing.T) {
+	t.Run("struct", func(t *testing.T) {
+		type S struct {
+			A int
+		}
+
+		g := New()
+		Add[S](g)
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A": number; }`)
+		AssertEqual(t, TypeOf[*S](g), "S | null")
+	})
+
+	t.Run("interface", func(t *testing.T) {
+		g := New()
+		Add[error](g)
+
+		AssertEqual(t, TypeOf[error](g), "any")
+	})
+}
+
+func TestRegisterGeneric(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	type Order struct {
+		ID int
+	}
+
+	type S struct {
+		Users  Page[User]   `json:"users"`
+		Orders *Page[Order] `json:"orders"`
+	}
+
+	var x S
+
+	g := New()
+	g.RegisterGeneric(reflect.TypeOf(Page[User]{}), "Page", reflect.TypeOf(User{}))
+	g.RegisterGeneric(reflect.TypeOf(Page[Order]{}), "Page", reflect.TypeOf(Order{}))
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Order { "ID": number; }
+interface Page<T> { "items": T[] | null; "next": number; }
+interface S { "users": Page<User>; "orders": Page<Order> | null; }
+interface User { "Name": string; }`)
+
+	AssertEqual(t, g.DeclarationsJSDoc(), `/**
+ * @typedef {Object} Order
+ * @property {number} ID
+ */
+/**
+ * @template T
+ * @typedef {Object} Page
+ * @property {T[] | null} items
+ * @property {number} next
+ */
+/**
+ * @typedef {Object} S
+ * @property {Page<User>} users
+ * @property {Page<Order> | null} orders
+ */
+/**
+ * @typedef {Object} User
+ * @property {string} Name
+ */`)
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
+}
+
+func TestRegisterGenericConcreteFields(t *testing.T) {
+	type S struct {
+		Counts Page[int]    `json:"counts"`
+		Names  Page[string] `json:"names"`
+	}
+
+	x := S{Counts: Page[int]{Items: []int{1}, Next: 2}}
+
+	g := New()
+	g.RegisterGeneric(reflect.TypeOf(Page[int]{}), "Page", reflect.TypeOf(0))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Page<T> { "items": T[] | null; "next": T; }`)
+
+	g.RegisterGeneric(reflect.TypeOf(Page[string]{}), "Page", reflect.TypeOf(""))
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Page<T> { "items": T[] | null; "next": number; }
+interface S { "counts": Page<number>; "names": Page<string>; }`)
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
+}
+
+type List[T any] []T
+
+func TestRegisterGenericNotStruct(t *testing.T) {
+	defer func() {
+		AssertEqual(t, recover(), any(`tsreflect: type "tsreflect.List[int]" is not a struct`))
+	}()
+
+	New().RegisterGeneric(reflect.TypeOf(List[int]{}), "List", reflect.TypeOf(0))
+}
+
+func TestRegisterConstField(t *testing.T) {
+	type User struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+	}
+
+	type Admin struct {
+		Kind  string `json:"kind"`
+		Admin bool   `json:"admin"`
+	}
+
+	x := User{Kind: "user", Name: "a"}
+
+	g := New()
+	g.RegisterConstField(reflect.TypeOf(User{}), "Kind", "user")
+	g.RegisterConstField(reflect.TypeOf(Admin{}), "Kind", "admin")
+	g.RegisterConstField(reflect.TypeOf(Admin{}), "Admin", true)
+	g.Add(reflect.TypeOf(x))
+	g.Add(reflect.TypeOf(Admin{}))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Admin { "kind": "admin"; "admin": true; }
+interface User { "kind": "user"; "name": string; }`)
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
+	AssertError(t, typecheckSource(source+`
+const admin: User = { kind: "admin", name: "a" }`))
+
+	defer func() {
+		AssertEqual(t, recover(), any(fmt.Sprintf(`tsreflect: type %q has no field "Missing"`, reflect.TypeOf(User{}).String())))
+	}()
+
+	g.RegisterConstField(reflect.TypeOf(User{}), "Missing", "")
+}
+
+func TestRegisterConstFieldNotStruct(t *testing.T) {
+	defer func() {
+		AssertEqual(t, recover(), any(`tsreflect: type "string" has no field "Kind"`))
+	}()
+
+	New().RegisterConstField(reflect.TypeOf(""), "Kind", "user")
+}
+
+func TestRegisterConstFieldBool(t *testing.T) {
+	type User struct {
+		Name string `json:"name"`
+	}
+
+	type Success struct {
+		Ok   bool `json:"ok"`
+		Data User `json:"data"`
+	}
+
+	type Failure struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+
+	g := New()
+	g.RegisterConstField(reflect.TypeOf(Success{}), "Ok", true)
+	g.RegisterConstField(reflect.TypeOf(Failure{}), "Ok", false)
+	g.Add(reflect.TypeOf(Success{}))
+	g.Add(reflect.TypeOf(Failure{}))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Failure { "ok": false; "error": string; }
+interface Success { "ok": true; "data": User; }
+interface User { "name": string; }`)
+
+	source := g.DeclarationsTypeScript() + `
+type Result = Success | Failure
+function name(r: Result): string { return r.ok ? r.data.name : r.error }`
+
+	AssertNoError(t, typecheckSource(source))
+	AssertError(t, typecheckSource(source+`
+const bad: Result = { ok: true, error: "a" }`))
+}
+
+type Level int
+
+func TestRegisterConstEnum(t *testing.T) {
+	type S struct {
+		Level  Level   `json:"level"`
+		Levels []Level `json:"levels"`
+	}
+
+	x := S{Level: 1}
+
+	g := New(WithExport())
+	g.RegisterConstEnum(reflect.TypeOf(Level(0)), EnumMember{"Debug", 0}, EnumMember{"Info", 1}, EnumMember{"Error", 2})
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `export const enum Level { Debug = 0, Info = 1, Error = 2 }
+export interface S { "level": Level; "levels": Level[] | null; }`)
+	AssertEqual(t, g.DeclarationsJSDoc(), `/** @typedef {0 | 1 | 2} Level */
+/**
+ * @typedef {Object} S
+ * @property {Level} level
+ * @property {Level[] | null} levels
+ */`)
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source+"\nconst level: Level = Level.Error"))
+
+	defer func() {
+		AssertEqual(t, recover(), any(`tsreflect: const enum member "in-fo" of "tsreflect.Level" is not a valid identifier`))
+	}()
+
+	g.RegisterConstEnum(reflect.TypeOf(Level(0)), EnumMember{"in-fo", 1})
+}
+
+func TestRegisterOpaque(t *testing.T) {
+	type Metadata struct {
+		Labels map[string]string
+	}
+
+	type S struct {
+		Meta  Metadata  `json:"meta"`
+		Extra *Metadata `json:"extra"`
+	}
+
+	x := S{Meta: Metadata{Labels: map[string]string{"a": "b"}}}
+
+	g := New()
+	g.RegisterOpaque(reflect.TypeOf(Metadata{}))
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "meta": Record<string, unknown>; "extra": Record<string, unknown> | null; }`)
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
+}
+
+type Status string
+
+type Enabled bool
+
+func TestRegisterEnum(t *testing.T) {
+	type S struct {
+		Status   Status   `json:"status"`
+		Statuses []Status `json:"statuses"`
+		Enabled  Enabled  `json:"enabled"`
+		Level    int      `json:"level"`
+		Previous *Status  `json:"previous,omitempty"`
+	}
+
+	x := S{Status: "active", Enabled: true, Level: 2}
+
+	g := New()
+	g.RegisterEnum(reflect.TypeOf(Status("")), "active", "inactive")
+	g.RegisterEnum(reflect.TypeOf(Enabled(false)), true)
+	g.RegisterEnum(reflect.TypeOf(0), 1, 2, 3)
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `type Enabled = true
+interface S { "status": Status; "statuses": Status[] | null; "enabled": Enabled; "level": 1 | 2 | 3; "previous"?: Status; }
+type Status = "active" | "inactive"`)
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
+
+	g = New()
+	g.RegisterEnum(reflect.TypeOf(false), true, false)
+
+	AssertEqual(t, g.TypeOf(reflect.TypeOf([]bool{})), "(true | false)[] | null")
+
+	defer func() {
+		AssertEqual(t, recover(), any(`tsreflect: enum value (1+1i) of "tsreflect.Status" can not be marshaled: json: unsupported type: complex128`))
+	}()
+
+	g.RegisterEnum(reflect.TypeOf(Status("")), 1i+1)
+}
+
+func TestAddPartial(t *testing.T) {
+	type User struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+
+	g := New()
+	g.AddPartial(reflect.TypeOf(User{}), "UserUpdate")
+	g.AddPartial(reflect.TypeOf(&struct{ A int }{}), "AnonymousUpdate")
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `type AnonymousUpdate = Partial<{ "A": number; }>
+interface User { "name": string; "email": string; }
+type UserUpdate = Partial<User>`)
+
+	AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()+`
+const update: UserUpdate = { name: "test" }`))
+
+	defer func() {
+		AssertEqual(t, recover(), any(`tsreflect: name "User" is taken`))
+	}()
+
+	g.AddPartial(reflect.TypeOf(User{}), "User")
+}
+
+func TestAddOmitPick(t *testing.T) {
+	type User struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+		Token    string `json:"token,omitempty"`
+	}
+
+	g := New()
+	g.AddOmit(reflect.TypeOf(User{}), "PublicUser", "password", "token")
+	g.AddPick(reflect.TypeOf(&User{}), "UserName", "name")
+	g.AddOmit(reflect.TypeOf(User{}), "FullUser")
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `type FullUser = Omit<User, never>
+type PublicUser = Omit<User, "password" | "token">
+interface User { "name": string; "password": string; "token"?: string; }
+type UserName = Pick<User, "name">`)
+
+	AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()+`
+const user: PublicUser = { name: "test" }
+const name: UserName = { name: "test" }`))
+	AssertError(t, typecheckSource(g.DeclarationsTypeScript()+`
+const user: PublicUser = { name: "test", password: "secret" }`))
+
+	defer func() {
+		AssertEqual(t, recover(), any(fmt.Sprintf(`tsreflect: type %q has no property "Password"`, reflect.TypeOf(User{}).String())))
+	}()
+
+	g.AddPick(reflect.TypeOf(User{}), "UserPassword", "Password")
+}
+
+func TestAddMessageUnion(t *testing.T) {
+	type Joined struct {
+		Type string `json:"type"`
+		User string `json:"user"`
+	}
+
+	type Left struct {
+		Type   string `json:"type"`
+		User   string `json:"user"`
+		Reason string `json:"reason"`
+	}
+
+	g := New()
+	g.RegisterConstField(reflect.TypeOf(Joined{}), "Type", "joined")
+	g.RegisterConstField(reflect.TypeOf(Left{}), "Type", "left")
+	g.AddMessageUnion("Message", reflect.TypeOf(Joined{}), reflect.TypeOf(&Left{}))
+	g.AddMessageUnion("Nothing")
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Joined { "type": "joined"; "user": string; }
+interface Left { "type": "left"; "user": string; "reason": string; }
+type Message = Joined | Left
+type Nothing = never`)
+
+	AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()+`
+function reason(m: Message): string { return m.type === "left" ? m.reason : "" }`))
+}
+
+func TestAddStream(t *testing.T) {
+	type Event struct {
+		Name string `json:"name"`
+	}
+
+	g := New()
+	g.AddStream(reflect.TypeOf(Event{}), "Events")
+	g.AddStream(reflect.TypeOf(&Event{}), "NullableEvents")
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Event { "name": string; }
+type Events = AsyncIterable<Event>
+type NullableEvents = AsyncIterable<Event | null>`)
+
+	g = New(WithReadableStreams())
+	g.AddStream(reflect.TypeOf(Event{}), "Events")
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Event { "name": string; }
+type Events = ReadableStream<Event>`)
+
+	AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()+`
+const events: Events = new ReadableStream<Event>()`))
+}
+
+func TestAddNamed(t *testing.T) {
+	type User struct {
+		Name string `json:"name"`
+	}
+
+	type Response struct {
+		Users  map[string]User `json:"users"`
+		Admins map[string]User `json:"admins,omitempty"`
+	}
+
+	x := map[string]User{"a": {Name: "a"}}
+
+	g := New()
+	g.AddNamed(reflect.TypeOf(x), "UserMap")
+	g.Add(reflect.TypeOf(Response{}))
+
+	AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), "UserMap | null")
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Response { "users": UserMap | null; "admins"?: UserMap; }
+interface User { "name": string; }
+type UserMap = { [key in (string)]: (User) }`)
+	AssertError(t, typecheckSource(g.DeclarationsTypeScript()+`
+const response: Response = { users: null, admins: null }`))
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
+
+	defer func() {
+		AssertEqual(t, recover(), any(`tsreflect: name "User" is taken`))
+	}()
+
+	g.AddNamed(reflect.TypeOf([]User{}), "User")
+}
+
+type Account struct {
+	ID int `json:"id"`
+}
+
+func (Account) TypeScriptName() string {
+	return "UserAccount"
+}
+
+func TestTypeScriptNamer(t *testing.T) {
+	type Response struct {
+		Account  Account   `json:"account"`
+		Accounts []Account `json:"accounts"`
+	}
+
+	x := Response{Account: Account{ID: 1}}
+
+	g := New(WithNamePrefix("Api"))
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface ApiResponse { "account": UserAccount; "accounts": UserAccount[] | null; }
+interface UserAccount { "id": number; }`)
+	AssertNoError(t, typecheckValue(x))
+
+	defer func() {
+		AssertEqual(t, recover(), any(`tsreflect: name "UserAccount" is taken`))
+	}()
+
+	g = New()
+	g.AddNamed(reflect.TypeOf([]int{}), "UserAccount")
+	g.Add(reflect.TypeOf(Account{}))
+}
+
+type Admin struct {
+	*Account
+	Admin bool `json:"admin"`
+}
+
+type Owner struct {
+	Account
+}
+
+func (Owner) TypeScriptName() string {
+	return "AccountOwner"
+}
+
+type Guest struct {
+	TypeScriptNamer `json:"-"`
+	Name            string `json:"name"`
+}
+
+func TestTypeScriptNamerPromoted(t *testing.T) {
+	x := Admin{Account: &Account{ID: 1}, Admin: true}
+
+	g := New()
+	g.Add(reflect.TypeOf(x))
+	g.Add(reflect.TypeOf(Owner{}))
+	g.Add(reflect.TypeOf(Guest{}))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface AccountOwner { "id": number; }
+interface Admin { "id"?: number; "admin": boolean; }
+interface Guest { "name": string; }
+interface UserAccount { "id": number; }`)
+	AssertNoError(t, typecheckValue(x))
+}
+
+func TestImport(t *testing.T) {
+	type Address struct {
+		City string
+	}
+
+	type User struct {
+		Address Address
+	}
+
+	type Order struct {
+		User  User
+		Items []Page[Address]
+	}
+
+	shared := New()
+	shared.Add(reflect.TypeOf(User{}))
+	shared.RegisterGeneric(reflect.TypeOf(Page[Address]{}), "Page", reflect.TypeOf(Address{}))
+
+	g := New(WithFlatten())
+	g.Import(shared)
+	g.Add(reflect.TypeOf(Order{}))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), "")
+	AssertEqual(t, g.TypeOf(reflect.TypeOf(Order{})), `{ "User": User; "Items": Page<Address>[] | null; }`)
+
+	source := fmt.Sprintf("%s\nconst test: %s = { User: { Address: { City: \"\" } }, Items: null }", shared.DeclarationsTypeScript(), g.TypeOf(reflect.TypeOf(Order{})))
+
+	AssertNoError(t, typecheckSource(source))
+}
+
+func TestImportEnums(t *testing.T) {
+	type Account struct {
+		Status Status `json:"status"`
+		Level  Level  `json:"level"`
+	}
+
+	shared := New()
+	shared.RegisterEnum(reflect.TypeOf(Status("")), "active", "inactive")
+	shared.RegisterConstEnum(reflect.TypeOf(Level(0)), EnumMember{"Info", 0}, EnumMember{"Error", 1})
+
+	g := New()
+	g.Import(shared)
+	g.Add(reflect.TypeOf(Account{}))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Account { "status": Status; "level": Level; }`)
+
+	source := shared.DeclarationsTypeScript() + "\n" + g.DeclarationsTypeScript() + `
+const account: Account = { status: "active", level: Level.Error }`
+
+	AssertNoError(t, typecheckSource(source))
+}
+
+func TestImportBrands(t *testing.T) {
+	type Event struct {
+		At time.Time `json:"at"`
+	}
+
+	type Log struct {
+		Events []Event   `json:"events"`
+		Since  time.Time `json:"since"`
+	}
+
+	shared := New(WithTimeAsBranded())
+	shared.Add(reflect.TypeOf(Event{}))
+
+	g := New(WithTimeAsBranded())
+	g.Import(shared)
+	g.Add(reflect.TypeOf(Log{}))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Log { "events": Event[] | null; "since": ISODateString; }`)
+
+	source := shared.DeclarationsTypeScript() + "\n" + g.DeclarationsTypeScript() + `
+const log: Log = { events: null, since: "" as ISODateString }`
+
+	AssertNoError(t, typecheckSource(source))
+}
+
+func TestTypeOfField(t *testing.T) {
+	type S struct {
+		A *int  `json:"a"`
+		B *int  `json:"b,omitempty"`
+		C int64 `json:"c,string"`
+		D Date
+	}
+
+	g := New()
+	typ := reflect.TypeOf(S{})
+
+	AssertEqual(t, g.TypeOfField(typ.Field(0)), "number | null")
+	AssertEqual(t, g.TypeOfField(typ.Field(1)), "number")
+	AssertEqual(t, g.TypeOfField(typ.Field(2)), "string")
+	AssertEqual(t, g.TypeOfField(typ.Field(3)), "Date")
+}
+
+func TestMaxDepth(t *testing.T) {
+	type S struct {
+		A struct {
+			B struct {
+				C []int
+			}
+		}
+	}
+
+	var x S
+
+	g := New(WithMaxDepth(3))
+	typ := reflect.TypeOf(x)
+
+	var message string
+	g.warn = func(s string, a ...any) {
+		message = fmt.Sprintf(s, a...)
+	}
+
+	g.Add(typ)
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "A": { "B": { "C": any[] | null; }; }; }`)
+	AssertEqual(t, message, `tsreflect: WARNING field S.A.B.C[]: maximum depth of 3 exceeded by type "int".`)
+}
+
+func TestValidate(t *testing.T) {
+	type S struct {
+		A int
+		B []string
+	}
+
+	g := New()
+	g.Add(reflect.TypeOf(S{}))
+
+	err := g.Validate()
+	if errors.Is(err, ErrNoCompiler) {
+		t.Skip(err)
+	}
+
+	AssertNoError(t, err)
+
+	g = New(WithTyper(reflect.TypeOf(S{}), func(g *Generator, typ reflect.Type, optional bool) string {
+		return "NotAType"
+	}))
+	g.Add(reflect.TypeOf(S{}))
+
+	AssertError(t, g.Validate())
+}
+
+func TestDeclarationHook(t *testing.T) {
+	type S struct {
+		A int
+	}
+
+	var x S
 
-	t.Run("chan", func(t *testing.T) {
-		x := make(chan struct{})
+	g := New(WithDeclarationHook(func(d Declaration) Declaration {
+		d.Type = fmt.Sprintf("/* %s */ %s", d.Name, d.Type)
+		return d
+	}))
+	g.Add(reflect.TypeOf(x))
 
-		AssertError(t, typecheckValue(x))
-	})
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface S /* S */ { "A": number; }`)
 
-	t.Run("func", func(t *testing.T) {
-		x := func() {}
+	source, err := programOfGenerator(g, x)
 
-		AssertError(t, typecheckValue(x))
-	})
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
+}
 
-	t.Run("unsafe pointer", func(t *testing.T) {
-		i := 10
-		x := unsafe.Pointer(&i)
+func TestDeclareGlobal(t *testing.T) {
+	type User struct {
+		Name string `json:"name"`
+	}
 
-		AssertError(t, typecheckValue(x))
-	})
+	type Window struct {
+		User User `json:"user"`
+	}
+
+	g := New(WithDeclareGlobal())
+	g.Add(reflect.TypeOf(Window{}))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `declare global {
+  interface User { "name": string; }
+  interface Window { "user": User; }
 }
+export {}`)
 
-type StringUnion string
+	local := New()
+	local.Add(reflect.TypeOf(Window{}))
 
-func (s StringUnion) MarshalJSON() ([]byte, error) {
-	return []byte(fmt.Sprintf("%q", s)), nil
+	AssertEqual(t, g.DeclarationsJSDoc(), local.DeclarationsJSDoc())
+
+	AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()+`
+const user: User = window.user`))
 }
 
-func (StringUnion) TypeScriptType(*Generator, bool) string {
-	return fmt.Sprintf("%q | %q", "test1", "test2")
+func TestJSONValueType(t *testing.T) {
+	type S struct {
+		Meta   map[string]interface{} `json:"meta"`
+		Items  []interface{}          `json:"items"`
+		Value  *any                   `json:"value"`
+		Errors []error                `json:"errors"`
+	}
+
+	x := S{
+		Meta:  map[string]interface{}{"a": 1, "b": []any{"c", true, nil}},
+		Items: []interface{}{map[string]any{"d": 1.5}},
+	}
+
+	g := New(WithJSONValueType(), WithErrorAs("string"))
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `type JSONValue = string | number | boolean | null | JSONValue[] | { [key: string]: JSONValue }
+interface S { "meta": { [key in (string)]: (JSONValue) } | null; "items": JSONValue[] | null; "value": JSONValue; "errors": string[] | null; }`)
+
+	AssertEqual(t, New().TypeOf(reflect.TypeOf(x.Items)), "any[] | null")
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
 }
 
-type NonNullSlice[T any] []T
+func TestTypeVisitor(t *testing.T) {
+	type User struct {
+		Name string            `json:"name"`
+		Tags map[string]Status `json:"tags"`
+	}
 
-func (s NonNullSlice[T]) MarshalJSON() ([]byte, error) {
-	if s == nil {
-		return []byte("[]"), nil
+	type Team struct {
+		Owner *User  `json:"owner"`
+		Users []User `json:"users"`
 	}
 
-	return json.Marshal([]T(s))
+	var visited []string
+
+	g := New(WithTypeVisitor(func(typ reflect.Type, path []string) {
+		visited = append(visited, fmt.Sprintf("%s %v", typ, path))
+	}))
+	g.Add(reflect.TypeOf(Team{}))
+	g.Add(reflect.TypeOf(User{}))
+
+	AssertEqual(t, strings.Join(visited, "\n"), `tsreflect.Team []
+*tsreflect.User [Owner]
+tsreflect.User [Owner]
+string [Owner Name]
+map[string]tsreflect.Status [Owner Tags]
+tsreflect.Status [Owner Tags {}]
+[]tsreflect.User [Users]`)
 }
 
-func (s NonNullSlice[T]) TypeScriptType(g *Generator, optional bool) string {
-	typ := reflect.TypeOf(s)
+func TestTypeRewriter(t *testing.T) {
+	type S struct {
+		Code   Status `json:"code"`
+		Name   string `json:"name"`
+		Extra  any    `json:"extra"`
+		Others []any  `json:"others"`
+	}
 
-	return fmt.Sprintf("Array<%s>", typ.Elem())
+	x := S{Code: "ok", Others: []any{1}}
+
+	g := New(WithTypeRewriter(func(g *Generator, typ reflect.Type, ts string) string {
+		switch {
+		case typ == reflect.TypeOf(Status("")):
+			return strings.ToUpper(g.TypeOf(typ)[:1]) + ts[1:]
+		case ts == "any":
+			return "unknown"
+		default:
+			return ts
+		}
+	}))
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface S { "code": String; "name": string; "extra": unknown; "others": unknown[] | null; }`)
+	AssertEqual(t, g.TypeOf(reflect.TypeOf(Status(""))), "String")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			AssertEqual(t, g.TypeOf(reflect.TypeOf([]Status{})), "String[] | null")
+		}()
+	}
+
+	wg.Wait()
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
 }
 
-type Base32Slice []byte
+func TestTypeAliases(t *testing.T) {
+	type Address struct {
+		City string
+	}
 
-func (s Base32Slice) MarshalJSON() ([]byte, error) {
-	out := base32.StdEncoding.EncodeToString(s)
+	type User struct {
+		Address Address
+	}
 
-	return []byte(fmt.Sprintf("%q", out)), nil
+	var x User
+
+	g := New()
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Address { "City": string; }
+interface User { "Address": Address; }`)
+
+	g = New(WithTypeAliases())
+	g.Add(reflect.TypeOf(x))
+	g.RegisterGeneric(reflect.TypeOf(Page[User]{}), "Page", reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `type Address = { "City": string; }
+type Page<T> = { "items": T[] | null; "next": number; }
+type User = { "Address": Address; }`)
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
+}
+
+func TestManifest(t *testing.T) {
+	type User struct {
+		Name string `json:"name"`
+	}
+
+	type Team struct {
+		Users []User `json:"users"`
+	}
+
+	g := New()
+	g.Add(reflect.TypeOf(Team{}))
+	g.AddNamed(reflect.TypeOf(func(User) error { return nil }), "Handler")
+
+	AssertEqual(t, string(g.Manifest()), `[{"name":"Handler","kind":"alias","function":true,"dependencies":["User"]},`+
+		`{"name":"Team","kind":"interface","function":false,"package":"github.com/olahol/tsreflect","type":"Team","dependencies":["User"]},`+
+		`{"name":"User","kind":"interface","function":false,"package":"github.com/olahol/tsreflect","type":"User","dependencies":[]}]`)
+	AssertEqual(t, string(New().Manifest()), "[]")
+
+	g = New(WithDeclarationHook(func(d Declaration) Declaration {
+		if d.Kind == InterfaceDeclaration {
+			d.Name = "I" + d.Name
+		}
+
+		return d
+	}))
+	g.Add(reflect.TypeOf(Team{}))
+
+	AssertEqual(t, string(g.Manifest()), `[{"name":"ITeam","kind":"interface","function":false,"package":"github.com/olahol/tsreflect","type":"Team","dependencies":["IUser"]},`+
+		`{"name":"IUser","kind":"interface","function":false,"package":"github.com/olahol/tsreflect","type":"User","dependencies":[]}]`)
+}
+
+func TestSchemaHash(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	type UserV2 struct {
+		Name  string
+		Email string
+	}
+
+	g1 := New()
+	g1.Add(reflect.TypeOf(User{}))
+
+	g2 := New(WithSchemaHashHeader())
+	g2.Add(reflect.TypeOf(User{}))
+
+	g3 := New(WithNamer(func(typ reflect.Type, isNameTaken func(string) bool) string {
+		return "User"
+	}))
+	g3.Add(reflect.TypeOf(UserV2{}))
+
+	AssertEqual(t, g1.SchemaHash(), g2.SchemaHash())
+	AssertEqual(t, g1.SchemaHash() != g3.SchemaHash(), true)
+	AssertEqual(t, len(g1.SchemaHash()), 64)
+	AssertEqual(t, g2.DeclarationsTypeScript(), fmt.Sprintf("// schema: %s\n%s", g1.SchemaHash(), g1.DeclarationsTypeScript()))
+	AssertEqual(t, g2.DeclarationsJSDoc(), fmt.Sprintf("// schema: %s\n%s", g1.SchemaHash(), g1.DeclarationsJSDoc()))
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	type Address struct {
+		City string
+	}
+
+	type User struct {
+		Address Address
+		Friends []Ping
+	}
+
+	type Account struct {
+		User User
+	}
+
+	var x Account
+
+	g := New(WithTopologicalOrder())
+	g.Add(reflect.TypeOf(x))
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Address { "City": string; }
+interface Pong { "ping": Ping; }
+interface Ping { "pong": Pong | null; }
+interface User { "Address": Address; "Friends": Ping[] | null; }
+interface Account { "User": User; }`)
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
+}
+
+func TestSourceReference(t *testing.T) {
+	type User struct {
+		Name string `json:"name"`
+	}
+
+	g := New(WithSourceReference())
+	g.Add(reflect.TypeOf(User{}))
+	g.RegisterGeneric(reflect.TypeOf(Page[User]{}), "Page", reflect.TypeOf(User{}))
+	g.AddPartial(reflect.TypeOf(User{}), "UserUpdate")
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `/** @see github.com/olahol/tsreflect.Page */
+interface Page<T> { "items": T[] | null; "next": number; }
+/** @see github.com/olahol/tsreflect.User */
+interface User { "name": string; }
+type UserUpdate = Partial<User>`)
+
+	AssertEqual(t, g.DeclarationsJSDoc(), `/**
+ * @template T
+ * @typedef {Object} Page
+ * @property {T[] | null} items
+ * @property {number} next
+ * @see github.com/olahol/tsreflect.Page
+ */
+/**
+ * @typedef {Object} User
+ * @property {string} name
+ * @see github.com/olahol/tsreflect.User
+ */
+/** @typedef {Partial<User>} UserUpdate */`)
+
+	AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()))
+}
+
+// schemaEmitter is an Emitter of a small schema language.
+type schemaEmitter struct {
+	TypeScriptEmitter
+}
+
+func (schemaEmitter) Primitive(kind reflect.Kind) string {
+	return kind.String()
+}
+
+func (schemaEmitter) Array(elem string) string {
+	return fmt.Sprintf("list(%s)", elem)
+}
+
+func (schemaEmitter) Nullable(typ string) string {
+	return fmt.Sprintf("nullable(%s)", typ)
+}
+
+func (schemaEmitter) Object(fields []Field) string {
+	props := make([]string, len(fields))
+	for i, f := range fields {
+		if f.Optional {
+			props[i] = fmt.Sprintf("%s?: %s", f.Name, f.Type)
+		} else {
+			props[i] = fmt.Sprintf("%s: %s", f.Name, f.Type)
+		}
+	}
+
+	return fmt.Sprintf("object(%s)", strings.Join(props, ", "))
+}
+
+func (schemaEmitter) Declare(d Declaration) string {
+	return fmt.Sprintf("%s = %s", d.Name, d.Type)
+}
+
+func TestEmit(t *testing.T) {
+	type Address struct {
+		City string `json:"city"`
+	}
+
+	type User struct {
+		Name      string            `json:"name"`
+		Age       int               `json:"age,omitempty"`
+		ID        int64             `json:"id,string"`
+		Tags      []string          `json:"tags"`
+		Address   *Address          `json:"address"`
+		Scores    map[string][2]int `json:"scores"`
+		CreatedAt time.Time         `json:"createdAt"`
+		Any       any               `json:"any"`
+		Status    Status            `json:"status"`
+		Inline    struct{ A bool }  `json:"inline"`
+	}
+
+	var x User
+
+	g := New()
+	g.RegisterEnum(reflect.TypeOf(Status("")), "active", "inactive")
+	g.Add(reflect.TypeOf(x))
+	g.AddPartial(reflect.TypeOf(x), "UserUpdate")
+
+	AssertEqual(t, g.Emit(TypeScriptEmitter{}), g.DeclarationsTypeScript())
+	AssertEqual(t, g.EmitType(TypeScriptEmitter{}, reflect.TypeOf([]User{})), g.TypeOf(reflect.TypeOf([]User{})))
+
+	AssertEqual(t, g.Emit(schemaEmitter{}), `Address = object(city: string)
+Status = "active" | "inactive"
+User = object(name: string, age?: int, id: string, tags: nullable(list(string)), address: nullable(Address), scores: nullable({ [key in (string)]: ([int, int]) }), createdAt: string, any: interface, status: Status, inline: object(A: bool))
+UserUpdate = Partial<User>`)
+}
+
+func TestEmitParity(t *testing.T) {
+	type Address struct {
+		City string `json:"city"`
+	}
+
+	type Empty struct{}
+
+	type User struct {
+		Name     string            `json:"name"`
+		Nick     *string           `json:"nick,omitempty"`
+		Age      *int              `json:"age"`
+		Tags     []string          `json:"tags"`
+		Pair     [2]int            `json:"pair"`
+		Scores   map[string]uint   `json:"scores"`
+		Address  *Address          `json:"address"`
+		Extra    any               `json:"extra"`
+		Status   Status            `json:"status"`
+		Created  time.Time         `json:"created"`
+		Empty    Empty             `json:"empty"`
+		Street   string            `json:"home.street"`
+		Zip      string            `json:"home.zip,omitempty"`
+		Secret   string            `json:"-"`
+		Counts   map[string]int    `json:"counts" ts:"type=Record<string, number>"`
+		Inline   struct{ A bool }  `json:"inline"`
+		Nested   [][]*Address      `json:"nested"`
+		Optional map[string]string `json:"optional,omitempty"`
+		Data     []byte            `json:"data"`
+	}
+
+	options := map[string]Option{
+		"none":                   nil,
+		"field terminator":       WithFieldTerminator(","),
+		"defensive optionals":    WithDefensiveOptionals(),
+		"compact null":           WithCompactNull(),
+		"readonly":               WithReadonly(),
+		"readonly arrays":        WithReadonlyArrays(),
+		"readonly fixed arrays":  WithReadonlyFixedArrays(),
+		"non-null collections":   WithNonNullCollections(),
+		"maximal nullability":    WithMaximalNullability(),
+		"omitempty nullable":     WithOmitemptyNullable(),
+		"all fields optional":    WithAllFieldsOptional(),
+		"dotted tag nesting":     WithDottedTagNesting(),
+		"type aliases":           WithTypeAliases(),
+		"flatten":                WithFlatten(),
+		"include omitted":        WithIncludeOmitted(),
+		"json value type":        WithJSONValueType(),
+		"branded unsigned":       WithBrandedUnsigned(),
+		"time as branded":        WithTimeAsBranded(),
+		"branded strings":        WithBrandedStrings(),
+		"empty records":          WithEmptyRecords(),
+		"max depth":              WithMaxDepth(2),
+		"bytes as number arrays": WithBytesAs(BytesNumberArray),
+		"name suffix":            WithNameSuffix("DTO"),
+		"export":                 WithExport(),
+		"declare global":         WithDeclareGlobal(),
+		"source reference":       WithSourceReference(),
+		"schema hash header":     WithSchemaHashHeader(),
+		"type only exports": func(g *Generator) {
+			WithExport()(g)
+			WithTypeOnlyExports()(g)
+		},
+		"type rewriter": WithTypeRewriter(func(g *Generator, typ reflect.Type, ts string) string {
+			if ts == "any" {
+				return "unknown"
+			}
+
+			return strings.ReplaceAll(ts, "number", "Number")
+		}),
+		"declaration hook": WithDeclarationHook(func(d Declaration) Declaration {
+			d.Type = strings.ReplaceAll(d.Type, "string", "String")
+			return d
+		}),
+	}
+
+	for name, option := range options {
+		t.Run(name, func(t *testing.T) {
+			g := New(WithNoWarnings())
+			if option != nil {
+				option(g)
+			}
+
+			g.Add(reflect.TypeOf(User{}))
+
+			AssertEqual(t, g.Emit(TypeScriptEmitter{}), g.DeclarationsTypeScript())
+			AssertEqual(t, g.EmitType(TypeScriptEmitter{}, reflect.TypeOf([]User{})), g.TypeOf(reflect.TypeOf([]User{})))
+			AssertEqual(t, g.EmitType(TypeScriptEmitter{}, reflect.TypeOf(map[string]*[]Address{})), g.TypeOf(reflect.TypeOf(map[string]*[]Address{})))
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	type Helper struct {
+		A int
+	}
+
+	type Root struct {
+		H Helper
+	}
+
+	t.Run("export everything", func(t *testing.T) {
+		var x Root
+
+		g := New(WithExport())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `export interface Helper { "A": number; }
+export interface Root { "H": Helper; }`)
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("export roots", func(t *testing.T) {
+		var x Root
+
+		g := New(WithExportRoots())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface Helper { "A": number; }
+export interface Root { "H": Helper; }`)
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("type only exports", func(t *testing.T) {
+		var x Root
+
+		g := New(WithExport(), WithTypeOnlyExports())
+		g.Add(reflect.TypeOf(x))
+		g.RegisterConstEnum(reflect.TypeOf(Level(0)), EnumMember{"Info", 0}, EnumMember{"Error", 1})
+		g.AddNamed(reflect.TypeOf([]Root{}), "Roots")
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface Helper { "A": number; }
+export const enum Level { Info = 0, Error = 1 }
+interface Root { "H": Helper; }
+type Roots = Root[]
+export type { Helper, Root, Roots }`)
+
+		g = New(WithExportRoots(), WithTypeOnlyExports())
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface Helper { "A": number; }
+interface Root { "H": Helper; }
+export type { Root }`)
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+
+		AssertEqual(t, New(WithTypeOnlyExports()).DeclarationsTypeScript(), "")
+	})
+}
+
+func TestDeterministic(t *testing.T) {
+	type Address struct {
+		Street string
+		City   string
+	}
+
+	type Tag struct {
+		Name string
+	}
+
+	type User struct {
+		Name      string
+		Addresses map[string]Address
+		Tags      []Tag
+		Friends   []*User
+		Settings  map[string]map[string]int
+		Callback  func(Tag) (Address, error)
+	}
+
+	generate := func() string {
+		g := New(WithExportRoots())
+		g.Add(reflect.TypeOf(User{}))
+		g.Add(reflect.TypeOf(Page[User]{}))
+		g.AddPartial(reflect.TypeOf(User{}), "UserUpdate")
+
+		return fmt.Sprintf("%s\n%s", g.DeclarationsTypeScript(), g.TypeOf(reflect.TypeOf(Page[User]{})))
+	}
+
+	want := generate()
+	for i := 0; i < 20; i++ {
+		AssertEqual(t, generate(), want)
+	}
+}
+
+type Page[T any] struct {
+	Items []T `json:"items"`
+	Next  int `json:"next"`
+}
+
+func TestNamer(t *testing.T) {
+	t.Run("camel case", func(t *testing.T) {
+		AssertEqual(t, pascalCase("domain.name"), "DomainName")
+		AssertEqual(t, pascalCase("snake_case"), "SnakeCase")
+		AssertEqual(t, pascalCase("kebab-case"), "KebabCase")
+		AssertEqual(t, pascalCase("camelCase"), "CamelCase")
+		AssertEqual(t, pascalCase("PascalCase"), "PascalCase")
+		AssertEqual(t, pascalCase("Space Name"), "SpaceName")
+		AssertEqual(t, pascalCase("path/Case/Name"), "Path/Case/Name")
+		AssertEqual(t, pascalCase("mixed_case-name"), "MixedCaseName")
+		AssertEqual(t, pascalCase("mixed case-name"), "MixedCaseName")
+		AssertEqual(t, pascalCase("mixed.case___name.kebab-----case..com"), "MixedCaseNameKebabCaseCom")
+		AssertEqual(t, pascalCase(".."), "")
+		AssertEqual(t, pascalCase("..relativeName"), "RelativeName")
+	})
+
+	t.Run("sanitize identifier", func(t *testing.T) {
+		AssertEqual(t, sanitizeIdentifier("Page[github.com/olahol/tsreflect.User]"), "PageUser")
+		AssertEqual(t, sanitizeIdentifier("Pair[int,map[string]main.User]"), "PairIntMapStringUser")
+		AssertEqual(t, sanitizeIdentifier("1st"), "_1st")
+		AssertEqual(t, isIdentifier("PageUser"), true)
+		AssertEqual(t, isIdentifier("$_Page2"), true)
+		AssertEqual(t, isIdentifier("2Page"), false)
+		AssertEqual(t, isIdentifier("Page[User]"), false)
+	})
+
+	t.Run("name prefix and suffix", func(t *testing.T) {
+		type Address struct {
+			City string
+		}
+
+		type User struct {
+			Address Address
+		}
+
+		var x User
+
+		g := New(WithNamePrefix("Api"), WithNameSuffix("DTO"))
+		g.Add(reflect.TypeOf(x))
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(x)), "ApiUserDTO")
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface ApiAddressDTO { "City": string; }
+interface ApiUserDTO { "Address": ApiAddressDTO; }`)
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("name affixes with sequential names", func(t *testing.T) {
+		type User struct {
+			Name string
+		}
+
+		g := New(WithNamePrefix("Api"))
+		g.AddPartial(reflect.TypeOf(internalPointer{}), "ApiUser")
+		g.Add(reflect.TypeOf(User{}))
+
+		AssertEqual(t, g.TypeOf(reflect.TypeOf(User{})), "ApiUser2")
+	})
+
+	t.Run("generic type names", func(t *testing.T) {
+		type User struct {
+			Name string
+		}
+
+		var x Page[User]
+
+		g := New()
+		typ := reflect.TypeOf(x)
+
+		var message string
+		g.warn = func(s string, a ...any) {
+			message = fmt.Sprintf(s, a...)
+		}
+
+		g.Add(typ)
+
+		AssertEqual(t, g.TypeOf(typ), "PageUser")
+		AssertEqual(t, message, fmt.Sprintf(`tsreflect: WARNING namer returned %q which is not a valid identifier, using "PageUser".`, typ.Name()))
+
+		source, err := programOfGenerator(g, x)
+
+		AssertNoError(t, err)
+		AssertNoError(t, typecheckSource(source))
+	})
+
+	t.Run("package path name", func(t *testing.T) {
+		AssertEqual(t, pkgPathName("github.com/olahol/tsreflect", "Generator"), "OlaholTsreflectGenerator")
+		AssertEqual(t, pkgPathName("github.com/shopspring/decimal", "Decimal"), "ShopspringDecimalDecimal")
+		AssertEqual(t, pkgPathName("encoding/json", "Decoder"), "EncodingJsonDecoder")
+		AssertEqual(t, pkgPathName("../test", "Struct"), "TestStruct")
+		AssertEqual(t, pkgPathName("snake_case", "Struct_Name"), "SnakeCaseStruct_Name")
+		AssertEqual(t, pkgPathName("empty//part", "Name"), "EmptyPartName")
+		AssertEqual(t, pkgPathName("", "Name"), "Name")
+	})
 }
 
-func (s Base32Slice) TypeScriptType(*Generator, bool) string {
-	return "string"
-}
+func TestConcurrentUse(t *testing.T) {
+	type User struct {
+		Name string `json:"name"`
+	}
 
-func TestCustomTypes(t *testing.T) {
-	t.Run("union", func(t *testing.T) {
-		var x StringUnion
+	type Order struct {
+		Users []User `json:"users"`
+	}
 
-		AssertError(t, typecheckValue(x))
+	type Team struct {
+		Owner *User `json:"owner"`
+	}
 
-		x = "test2"
+	types := []reflect.Type{reflect.TypeOf(User{}), reflect.TypeOf(Order{}), reflect.TypeOf(Team{})}
+	for i := 0; i < 16; i++ {
+		types = append(types, reflect.StructOf([]reflect.StructField{
+			{Name: fmt.Sprintf("F%d", i), Type: reflect.TypeOf(0)},
+		}))
+	}
 
-		AssertNoError(t, typecheckValue(x))
-	})
+	g := New()
 
-	t.Run("generics", func(t *testing.T) {
-		var x NonNullSlice[string]
+	var wg sync.WaitGroup
+	for _, typ := range types {
+		wg.Add(1)
 
-		AssertNoError(t, typecheckValue(x))
+		go func(typ reflect.Type) {
+			defer wg.Done()
 
-		x = append(x, "test")
+			g.Add(typ)
+			g.TypeOf(typ)
+			g.Declarations()
+			g.DeclarationsTypeScript()
+		}(typ)
+	}
 
-		AssertNoError(t, typecheckValue(x))
-	})
+	wg.Wait()
 
-	t.Run("transform", func(t *testing.T) {
-		var x Base32Slice
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Order { "users": User[] | null; }
+interface Team { "owner": User | null; }
+interface User { "name": string; }`)
+	AssertEqual(t, g.TypeOf(types[len(types)-1]), `{ "F15": number; }`)
+}
 
-		AssertNoError(t, typecheckValue(x))
+func TestConcurrentTyper(t *testing.T) {
+	type ID struct {
+		Value int
+	}
 
-		x = []byte("test")
+	type User struct {
+		ID ID `json:"id"`
+	}
 
-		AssertNoError(t, typecheckValue(x))
-	})
+	var wg sync.WaitGroup
 
-	t.Run("with typer", func(t *testing.T) {
-		type S struct {
-			A string
-		}
+	var g *Generator
+	g = New(WithTyper(reflect.TypeOf(ID{}), func(v *Generator, typ reflect.Type, optional bool) string {
+		wg.Add(1)
 
-		var x S
+		go func() {
+			defer wg.Done()
 
-		typ := reflect.TypeOf(x)
+			g.Add(reflect.TypeOf(User{}))
+		}()
 
-		AssertNoError(t, typecheckValue(x, WithTyper(typ, func(g *Generator, typ reflect.Type, optional bool) string {
-			return "Record<string, string>"
-		})))
+		// Give the writer time to wait for the lock held by TypeOf.
+		time.Sleep(10 * time.Millisecond)
 
-		AssertNoError(t, typecheckValue(x))
-	})
-}
+		return v.TypeOf(reflect.TypeOf(0)) + " & { __id: void }"
+	}))
 
-func TestBuiltin(t *testing.T) {
-	t.Run("rune", func(t *testing.T) {
-		//x := '⌘'
-		//x := []rune{'a', 'b', 'c'}
-		var x []rune
+	done := make(chan string)
 
-		AssertNoError(t, typecheckValue(x))
-	})
+	go func() {
+		done <- g.TypeOf(reflect.TypeOf(ID{}))
+	}()
 
-	t.Run("[]byte should be typed as string | null", func(t *testing.T) {
-		var x []byte
+	select {
+	case ts := <-done:
+		AssertEqual(t, ts, "number & { __id: void }")
+	case <-time.After(5 * time.Second):
+		t.Fatal("typer calling TypeOf deadlocked")
+	}
 
-		AssertNoError(t, typecheckValue(x))
-	})
+	wg.Wait()
 
-	t.Run("time.Time should be typed as string", func(t *testing.T) {
-		var x time.Time
+	defer func() {
+		AssertEqual(t, recover(), any("tsreflect: generator is changed by a typer"))
+	}()
 
-		AssertNoError(t, typecheckValue(x))
-	})
+	New(WithTyper(reflect.TypeOf(ID{}), func(v *Generator, typ reflect.Type, optional bool) string {
+		v.Add(reflect.TypeOf(User{}))
+		return "never"
+	})).TypeOf(reflect.TypeOf(ID{}))
+}
 
-	t.Run("big.Int should be typed as 'number | null'", func(t *testing.T) {
-		x := big.NewInt(99)
+func BenchmarkPackageNamer(b *testing.B) {
+	type Leaf struct {
+		A string
+	}
 
-		AssertNoError(t, typecheckValue(x))
-	})
-}
+	type Node struct {
+		Leaf     Leaf
+		Children []Node
+		Values   map[string]Leaf
+		Headers  http.Header
+		At       time.Time
+	}
 
-type Marshaled struct {
-	A int
-}
+	b.ReportAllocs()
 
-func (Marshaled) MarshalJSON() ([]byte, error) {
-	return []byte("string"), nil
+	for i := 0; i < b.N; i++ {
+		g := New(WithNamer(PackageNamer))
+		g.Add(reflect.TypeOf(Node{}))
+		g.DeclarationsTypeScript()
+	}
 }
 
-func TestWarning(t *testing.T) {
-	t.Run("should warn of missing typer", func(t *testing.T) {
-		var x Marshaled
-
-		g := New()
-		typ := reflect.TypeOf(x)
+func TestRandomValues(t *testing.T) {
+	type Embedded struct {
+		E string `json:"e,omitempty"`
+	}
 
-		var called bool
-		g.warn = func(s string, a ...any) {
-			called = true
-		}
+	type Node struct {
+		Value    int     `json:"value"`
+		Children []*Node `json:"children,omitempty"`
+	}
 
-		g.Add(typ)
-		g.TypeOf(typ)
+	type S struct {
+		Embedded
+		*internalPointer
+		A int                    `json:"a,omitempty"`
+		B *int                   `json:"b,omitempty"`
+		C int64                  `json:"cs,string"`
+		D *float64               `json:"d,string,omitempty"`
+		E []string               `json:"e2"`
+		F map[string][]int       `json:"f,omitempty"`
+		G [2]bool                `json:"g"`
+		H *Node                  `json:"h"`
+		I map[int]*Embedded      `json:"i"`
+		J time.Time              `json:"j"`
+		K *time.Time             `json:"k,omitempty"`
+		L []byte                 `json:"l"`
+		M struct{ N []*string }  `json:"m"`
+		O map[string]struct{}    `json:"o,omitempty"`
+		P Status                 `json:"p"`
+		Q [0]int                 `json:"q,omitempty"`
+		R []map[string]*Embedded `json:"r"`
+	}
 
-		AssertEqual(t, called, true)
-	})
+	options := [][]Option{
+		{},
+		{WithFlatten()},
+		{WithOmitemptyNullable()},
+		{WithMaximalNullability()},
+		{WithTypeAliases(), WithEmptyRecords()},
+	}
 
-	t.Run("should not warn of missing typer", func(t *testing.T) {
-		var x Marshaled
+	r := rand.New(rand.NewSource(1))
 
-		g := New(WithNoWarnings())
-		typ := reflect.TypeOf(x)
+	for _, os := range options {
+		g := New(os...)
+		g.Add(reflect.TypeOf(S{}))
 
-		var called bool
-		g.warn = func(s string, a ...any) {
-			called = true
-		}
+		var sb strings.Builder
+		sb.WriteString(g.DeclarationsTypeScript())
 
-		g.Add(typ)
-		g.TypeOf(typ)
+		for i := 0; i < 50; i++ {
+			v := randomValue(r, reflect.TypeOf(S{}), 1+i%6)
 
-		AssertEqual(t, called, false)
-	})
-}
+			value, err := json.Marshal(v.Interface())
 
-func TestNamer(t *testing.T) {
-	t.Run("camel case", func(t *testing.T) {
-		AssertEqual(t, pascalCase("domain.name"), "DomainName")
-		AssertEqual(t, pascalCase("snake_case"), "SnakeCase")
-		AssertEqual(t, pascalCase("kebab-case"), "KebabCase")
-		AssertEqual(t, pascalCase("camelCase"), "CamelCase")
-		AssertEqual(t, pascalCase("PascalCase"), "PascalCase")
-		AssertEqual(t, pascalCase("Space Name"), "SpaceName")
-		AssertEqual(t, pascalCase("path/Case/Name"), "Path/Case/Name")
-		AssertEqual(t, pascalCase("mixed_case-name"), "MixedCaseName")
-		AssertEqual(t, pascalCase("mixed case-name"), "MixedCaseName")
-		AssertEqual(t, pascalCase("mixed.case___name.kebab-----case..com"), "MixedCaseNameKebabCaseCom")
-		AssertEqual(t, pascalCase(".."), "")
-		AssertEqual(t, pascalCase("..relativeName"), "RelativeName")
-	})
+			AssertNoError(t, err)
+			sb.WriteString(fmt.Sprintf("\nconst test%d: %s = %s", i, g.TypeOf(reflect.TypeOf(S{})), value))
+		}
 
-	t.Run("package path name", func(t *testing.T) {
-		AssertEqual(t, pkgPathName("github.com/olahol/tsreflect", "Generator"), "OlaholTsreflectGenerator")
-		AssertEqual(t, pkgPathName("github.com/shopspring/decimal", "Decimal"), "ShopspringDecimalDecimal")
-		AssertEqual(t, pkgPathName("encoding/json", "Decoder"), "EncodingJsonDecoder")
-		AssertEqual(t, pkgPathName("../test", "Struct"), "TestStruct")
-		AssertEqual(t, pkgPathName("snake_case", "Struct_Name"), "SnakeCaseStruct_Name")
-		AssertEqual(t, pkgPathName("empty//part", "Name"), "EmptyPartName")
-		AssertEqual(t, pkgPathName("", "Name"), "Name")
-	})
+		AssertNoError(t, typecheckSource(sb.String()))
+	}
 }
 
 func TestCoverage(t *testing.T) {
@@ -714,7 +3634,70 @@ func TestCoverage(t *testing.T) {
 		g := New()
 		g.Add(reflect.TypeOf(x))
 
-		AssertEqual(t, g.DeclarationsJSDoc(), `/** @typedef {{ "a": string; }} S */`)
+		AssertEqual(t, g.DeclarationsJSDoc(), `/**
+ * @typedef {Object} S
+ * @property {string} a
+ */`)
+	})
+
+	t.Run("jsdoc optional and nullable properties", func(t *testing.T) {
+		type User struct {
+			Name string `json:"name"`
+		}
+
+		type S struct {
+			A int   `json:"a"`
+			B int   `json:"b,omitempty"`
+			C *User `json:"c"`
+			D []int `json:"d"`
+		}
+
+		type Dashed struct {
+			A int `json:"a-b"`
+		}
+
+		g := New()
+		g.Add(reflect.TypeOf(S{}))
+		g.Add(reflect.TypeOf(Dashed{}))
+
+		AssertEqual(t, g.DeclarationsJSDoc(), `/** @typedef {{ "a-b": number; }} Dashed */
+/**
+ * @typedef {Object} S
+ * @property {number} a
+ * @property {number} [b]
+ * @property {?User} c
+ * @property {number[] | null} d
+ */
+/**
+ * @typedef {Object} User
+ * @property {string} name
+ */`)
+	})
+
+	t.Run("jsdoc hooked declarations", func(t *testing.T) {
+		type S struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		}
+
+		g := New(WithDeclarationHook(func(d Declaration) Declaration {
+			d.Type = strings.Replace(d.Type, `"name": string`, `"name": number`, 1)
+			return d
+		}))
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsJSDoc(), `/** @typedef {{ "name": number; "age": number; }} S */`)
+
+		g = New(WithDeclarationHook(func(d Declaration) Declaration {
+			return d
+		}))
+		g.Add(reflect.TypeOf(S{}))
+
+		AssertEqual(t, g.DeclarationsJSDoc(), `/**
+ * @typedef {Object} S
+ * @property {string} name
+ * @property {number} age
+ */`)
 	})
 
 	t.Run("bad namer", func(t *testing.T) {
@@ -762,6 +3745,54 @@ func TestCoverage(t *testing.T) {
 		g.Add(reflect.TypeOf(x))
 		g.Add(reflect.TypeOf(y))
 	})
+
+	t.Run("namer collision policies", func(t *testing.T) {
+		type S1 struct {
+			A string `json:"a"`
+		}
+
+		type S2 struct {
+			A string `json:"a"`
+		}
+
+		namer := WithNamer(func(typ reflect.Type, isNameTaken func(name string) bool) string {
+			return "Name"
+		})
+
+		g := New(namer, WithNamerCollisionPolicy(NamerCollisionSuffix))
+		g.Add(reflect.TypeOf(S1{}))
+		g.Add(reflect.TypeOf(S2{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface Name { "a": string; }
+interface Name2 { "a": string; }`)
+		AssertNoError(t, g.Err())
+
+		g = New(namer, WithNamerCollisionPolicy(NamerCollisionError))
+		g.Add(reflect.TypeOf(S1{}))
+		AssertNoError(t, g.Err())
+		g.Add(reflect.TypeOf(S2{}))
+
+		AssertEqual(t, g.DeclarationsTypeScript(), `interface Name { "a": string; }
+interface Name2 { "a": string; }`)
+		AssertError(t, g.Err())
+		AssertEqual(t, g.Err().Error(), `tsreflect: namer returned taken name "Name" for type "tsreflect.S2"`)
+
+		func() {
+			defer func() {
+				AssertEqual(t, recover(), any(`tsreflect: namer returned taken name "Name"`))
+			}()
+
+			g = New(namer, WithNamerCollisionPolicy(NamerCollisionPanic))
+			g.Add(reflect.TypeOf(S1{}))
+			g.Add(reflect.TypeOf(S2{}))
+		}()
+
+		defer func() {
+			AssertEqual(t, recover(), any(`tsreflect: unknown namer collision policy "ignore"`))
+		}()
+
+		New(WithNamerCollisionPolicy("ignore"))
+	})
 }
 
 type Date time.Time
@@ -800,7 +3831,7 @@ func TestBugs(t *testing.T) {
 		AssertNoError(t, typecheckValue(x))
 	})
 
-	t.Run("byte slices should have type (string | null)", func(t *testing.T) {
+	t.Run("byte slices should have type string | null", func(t *testing.T) {
 		var x []byte
 
 		AssertNoError(t, typecheckValue(x))
@@ -836,6 +3867,209 @@ func TestBugs(t *testing.T) {
 		g := New()
 		g.Add(typ)
 
-		AssertEqual(t, "(Date | null)", g.TypeOf(typ))
+		AssertEqual(t, "Date | null", g.TypeOf(typ))
+	})
+}
+
+type Figure interface {
+	Area() float64
+}
+
+type Circle struct {
+	Radius float64 `json:"radius"`
+}
+
+func (c Circle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+type Square struct {
+	Kind string  `json:"type"`
+	Side float64 `json:"side"`
+}
+
+func (s *Square) Area() float64 { return s.Side * s.Side }
+
+type Triangle struct {
+	Type string  `json:"kind"`
+	Base float64 `json:"base"`
+}
+
+func (t Triangle) Area() float64 { return t.Base / 2 }
+
+func TestAddUnion(t *testing.T) {
+	figure := reflect.TypeOf((*Figure)(nil)).Elem()
+
+	g := New()
+	g.AddUnion(figure, []reflect.Type{reflect.TypeOf(Circle{}), reflect.TypeOf(&Square{})}, "type")
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Circle { "radius": number; }
+interface Square { "type": string; "side": number; }`)
+	AssertEqual(t, g.TypeOf(figure), `Circle & { "type": "Circle"; } | Square & { "type": "Square"; }`)
+	AssertEqual(t, g.TypeOf(reflect.TypeOf([]Figure{})), `(Circle & { "type": "Circle"; } | Square & { "type": "Square"; })[] | null`)
+
+	AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()+`
+function area(f: `+g.TypeOf(figure)+`): number { return f.type === "Square" ? f.side * f.side : 3 * f.radius * f.radius }
+const circle: Circle = { radius: 1 }`))
+	AssertError(t, typecheckSource(g.DeclarationsTypeScript()+`
+const f: `+g.TypeOf(figure)+` = { type: "Circle", side: 1 }`))
+
+	plain := New()
+	plain.AddUnion(figure, []reflect.Type{reflect.TypeOf(Circle{}), reflect.TypeOf(&Square{})}, "")
+
+	AssertEqual(t, plain.DeclarationsTypeScript(), `interface Circle { "radius": number; }
+interface Square { "type": string; "side": number; }`)
+	AssertEqual(t, plain.TypeOf(figure), "Circle | Square")
+
+	defer func() {
+		AssertEqual(t, recover(), any(`tsreflect: type "int" is not a struct that implements "tsreflect.Figure"`))
+	}()
+
+	plain.AddUnion(figure, []reflect.Type{reflect.TypeOf(0)}, "")
+}
+
+func TestAddUnionElements(t *testing.T) {
+	type Drawing struct {
+		Figures []Figure          `json:"figures"`
+		Layers  map[string]Figure `json:"layers"`
+		Focus   *Figure           `json:"focus"`
+	}
+
+	figure := reflect.TypeOf((*Figure)(nil)).Elem()
+
+	g := New()
+	g.AddUnion(figure, []reflect.Type{reflect.TypeOf(Circle{}), reflect.TypeOf(&Square{})}, "")
+	g.Add(reflect.TypeOf(Drawing{}))
+
+	AssertEqual(t, g.TypeOf(reflect.TypeOf(map[string]Figure{})), "{ [key in (string)]: (Circle | Square) } | null")
+	AssertEqual(t, g.TypeOf(reflect.TypeOf((*Figure)(nil))), "Circle | Square | null")
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Circle { "radius": number; }
+interface Drawing { "figures": (Circle | Square)[] | null; "layers": { [key in (string)]: (Circle | Square) } | null; "focus": Circle | Square | null; }
+interface Square { "type": string; "side": number; }`)
+	AssertEqual(t, g.Emit(TypeScriptEmitter{}), g.DeclarationsTypeScript())
+
+	x := Drawing{
+		Figures: []Figure{Circle{Radius: 1}},
+		Layers:  map[string]Figure{"top": &Square{Kind: "square", Side: 2}},
+	}
+
+	source, err := programOfGenerator(g, x)
+
+	AssertNoError(t, err)
+	AssertNoError(t, typecheckSource(source))
+}
+
+type Hexagon struct {
+	Type string  `json:"kind"`
+	Side float64 `json:"side"`
+}
+
+func (h Hexagon) Area() float64 { return 2.6 * h.Side * h.Side }
+
+type Oval struct {
+	Type  string  `json:"shape"`
+	Width float64 `json:"width"`
+}
+
+func (o Oval) Area() float64 { return o.Width }
+
+func TestAddUnionDefaultDiscriminator(t *testing.T) {
+	figure := reflect.TypeOf((*Figure)(nil)).Elem()
+
+	t.Run("same name", func(t *testing.T) {
+		g := New()
+		g.AddUnion(figure, []reflect.Type{reflect.TypeOf(Triangle{}), reflect.TypeOf(Hexagon{})}, "")
+
+		AssertEqual(t, g.TypeOf(figure), `Triangle & { "kind": "Triangle"; } | Hexagon & { "kind": "Hexagon"; }`)
+	})
+
+	t.Run("mismatched name", func(t *testing.T) {
+		defer func() {
+			AssertEqual(t, recover(), any(`tsreflect: implementers of "tsreflect.Figure" do not all have a field Type with the same name`))
+		}()
+
+		New().AddUnion(figure, []reflect.Type{reflect.TypeOf(Triangle{}), reflect.TypeOf(Oval{})}, "")
+	})
+
+	t.Run("missing field", func(t *testing.T) {
+		defer func() {
+			AssertEqual(t, recover(), any(`tsreflect: implementers of "tsreflect.Figure" do not all have a field Type with the same name`))
+		}()
+
+		New().AddUnion(figure, []reflect.Type{reflect.TypeOf(Triangle{}), reflect.TypeOf(Circle{})}, "")
 	})
+
+	t.Run("explicit discriminator", func(t *testing.T) {
+		g := New()
+		g.AddUnion(figure, []reflect.Type{reflect.TypeOf(Triangle{}), reflect.TypeOf(Oval{})}, "type")
+
+		AssertEqual(t, g.TypeOf(figure), `Triangle & { "type": "Triangle"; } | Oval & { "type": "Oval"; }`)
+	})
+}
+
+type Node interface {
+	Size() int
+}
+
+type Leaf struct {
+	Value int `json:"value"`
+}
+
+func (Leaf) Size() int { return 1 }
+
+type Group struct {
+	Kids []Node `json:"kids"`
+}
+
+func (g Group) Size() int { return len(g.Kids) }
+
+func TestAddUnionRecursive(t *testing.T) {
+	node := reflect.TypeOf((*Node)(nil)).Elem()
+	impls := []reflect.Type{reflect.TypeOf(Leaf{}), reflect.TypeOf(Group{})}
+
+	g := New(WithFlatten())
+	g.AddUnion(node, impls, "")
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Group { "kids": ({ "value": number; } | Group)[] | null; }`)
+	AssertEqual(t, g.TypeOf(node), `{ "value": number; } | Group`)
+
+	g = New()
+	g.SetInline(reflect.TypeOf(Group{}), true)
+	g.AddUnion(node, impls, "")
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface Group { "kids": (Leaf | Group)[] | null; }
+interface Leaf { "value": number; }`)
+	AssertEqual(t, g.TypeOf(node), "Leaf | Group")
+
+	AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()+`
+const tree: `+g.TypeOf(node)+` = { kids: [{ value: 1 }, { kids: null }] }`))
+}
+
+type Expr interface {
+	Eval() float64
+}
+
+type Num struct {
+	Value float64 `json:"value"`
+}
+
+func (n Num) Eval() float64 { return n.Value }
+
+type BinOp struct {
+	Op    string `json:"op"`
+	Left  Expr   `json:"left"`
+	Right Expr   `json:"right"`
+}
+
+func (b *BinOp) Eval() float64 { return b.Left.Eval() + b.Right.Eval() }
+
+func TestAddUnionExpr(t *testing.T) {
+	expr := reflect.TypeOf((*Expr)(nil)).Elem()
+
+	g := New(WithFlatten())
+	g.AddUnion(expr, []reflect.Type{reflect.TypeOf(Num{}), reflect.TypeOf(&BinOp{})}, "kind")
+
+	AssertEqual(t, g.DeclarationsTypeScript(), `interface BinOp { "op": string; "left": { "value": number; } & { "kind": "Num"; } | BinOp & { "kind": "BinOp"; }; "right": { "value": number; } & { "kind": "Num"; } | BinOp & { "kind": "BinOp"; }; }`)
+	AssertEqual(t, g.TypeOf(expr), `{ "value": number; } & { "kind": "Num"; } | BinOp & { "kind": "BinOp"; }`)
+
+	AssertNoError(t, typecheckSource(g.DeclarationsTypeScript()+`
+const e: `+g.TypeOf(expr)+` = { kind: "BinOp", op: "+", left: { kind: "Num", value: 1 }, right: { kind: "Num", value: 2 } }`))
 }
@@ -0,0 +1,59 @@
+package tsreflect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoCompiler is returned by Validate when the TypeScript compiler `tsc`
+// can not be found.
+var ErrNoCompiler = errors.New("tsreflect: tsc not found")
+
+// Validate type checks the declarations of the generator, and a usage of every
+// type added to it, with the TypeScript compiler `tsc`. It returns
+// ErrNoCompiler if `tsc` is not installed.
+func (g *Generator) Validate() error {
+	var sb strings.Builder
+
+	g.rlock()
+
+	sb.WriteString(g.declarations(false))
+
+	for i, typ := range g.roots {
+		sb.WriteString(fmt.Sprintf("\ndeclare const type%d: %s", i, g.typeOf(scope{}, typ, false)))
+	}
+
+	g.runlock()
+
+	return typecheck(sb.String())
+}
+
+func typecheck(source string) error {
+	if _, err := exec.LookPath("tsc"); err != nil {
+		return ErrNoCompiler
+	}
+
+	dir, err := os.MkdirTemp("", "tsreflect")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "validate.ts")
+
+	if err := os.WriteFile(file, []byte(source), 0600); err != nil {
+		return err
+	}
+
+	bs, err := exec.Command("tsc", "--noEmit", file).Output()
+	if err != nil {
+		return fmt.Errorf("tsreflect: tsc: %s", bs)
+	}
+
+	return nil
+}